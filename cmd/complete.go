@@ -0,0 +1,279 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"simple_sh/internal/lineedit"
+	"simple_sh/internal/parser"
+	"simple_sh/internal/util"
+	"sort"
+	"strings"
+)
+
+// completeLine is the Tab completer of the line editor. The first word of a
+// command completes to builtins and executables on $PATH, later words to
+// files, and a word ending in $NAME or ${NAME to variable names.
+func completeLine(line string) (int, []lineedit.Candidate) {
+	start, quote, commandPosition := currentWord(line)
+	raw := line[start:]
+
+	// $VAR and ${VAR
+	if dollar := strings.LastIndexByte(raw, '$'); dollar >= 0 && quote != '\'' {
+		name := raw[dollar+1:]
+		braced := strings.HasPrefix(name, "{")
+		name = strings.TrimPrefix(name, "{")
+		if name == "" || parser.IsName(name) {
+			return start, completeVariable(raw[:dollar], name, braced)
+		}
+	}
+
+	value := unquoteWord(raw, quote)
+
+	if commandPosition && !strings.Contains(value, "/") {
+		return start, completeCommand(value)
+	}
+
+	var candidates []lineedit.Candidate
+	for _, path := range completePath(value, commandPosition) {
+		text := quoteWord(path, quote)
+		if quote != 0 && !strings.HasSuffix(path, "/") {
+			text += string(quote) // a complete file name closes the quote
+		}
+		candidates = append(candidates, lineedit.Candidate{
+			Text:    text,
+			Display: filepath.Base(path) + suffixIfDir(path),
+		})
+	}
+	return start, candidates
+}
+
+// currentWord scans the line to find where the word under the cursor starts,
+// the quote it is inside of (0 when none) and whether it is a command name,
+// ie. the first word after the start of the line or an operator.
+func currentWord(line string) (start int, quote byte, commandPosition bool) {
+	commandPosition = true
+	start = 0
+	inWord := false
+
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+
+		if quote != 0 {
+			if ch == quote {
+				quote = 0
+			} else if ch == '\\' && quote == '"' {
+				i++
+			}
+			continue
+		}
+
+		switch {
+		case ch == '\\':
+			i++
+			if !inWord {
+				start, inWord = i-1, true
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+			if !inWord {
+				start, inWord = i, true
+			}
+		case ch == ' ' || ch == '\t':
+			if inWord {
+				commandPosition = false
+				inWord = false
+			}
+		case strings.IndexByte("|&;()", ch) >= 0:
+			commandPosition = true
+			inWord = false
+		case ch == '<' || ch == '>':
+			commandPosition = false
+			inWord = false
+		default:
+			if !inWord {
+				start, inWord = i, true
+			}
+		}
+	}
+
+	if !inWord {
+		start = len(line)
+	}
+	return start, quote, commandPosition
+}
+
+// unquoteWord removes the quotes and backslashes of a partially typed word
+func unquoteWord(raw string, quote byte) string {
+	var sb strings.Builder
+	var inQuote byte
+
+	for i := 0; i < len(raw); i++ {
+		ch := raw[i]
+		switch {
+		case inQuote != 0 && ch == inQuote:
+			inQuote = 0
+		case inQuote == 0 && (ch == '\'' || ch == '"'):
+			inQuote = ch
+		case ch == '\\' && inQuote != '\'' && i+1 < len(raw):
+			i++
+			sb.WriteByte(raw[i])
+		default:
+			sb.WriteByte(ch)
+		}
+	}
+	return sb.String()
+}
+
+// quoteWord writes value back the way the word was being typed: inside the
+// same quotes, or with special characters escaped by backslashes
+func quoteWord(value string, quote byte) string {
+	switch quote {
+	case '\'':
+		return "'" + value
+	case '"':
+		var sb strings.Builder
+		sb.WriteByte('"')
+		for _, ch := range value {
+			if strings.ContainsRune("\"\\$`", ch) {
+				sb.WriteByte('\\')
+			}
+			sb.WriteRune(ch)
+		}
+		return sb.String()
+	}
+
+	var sb strings.Builder
+	for i, ch := range value {
+		if strings.ContainsRune(" \t'\"\\$&;|<>()*?[]#{}!`", ch) || (ch == '~' && i > 0) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(ch)
+	}
+	return sb.String()
+}
+
+func completeCommand(prefix string) []lineedit.Candidate {
+	seen := map[string]bool{}
+
+	for name := range builtins {
+		if strings.HasPrefix(name, prefix) {
+			seen[name] = true
+		}
+	}
+
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if seen[name] || !strings.HasPrefix(name, prefix) {
+				continue
+			}
+			if isExecutable(filepath.Join(dir, name)) {
+				seen[name] = true
+			}
+		}
+	}
+
+	return sortedCandidates(seen)
+}
+
+// completePath lists the files whose path starts with value. The directory
+// part is kept as typed, so ~/Doc completes to ~/Documents/. For a command
+// name only directories and executables are offered.
+func completePath(value string, executablesOnly bool) []string {
+	if value == "~" {
+		return []string{"~/"}
+	}
+
+	dir, prefix := "", value
+	if slash := strings.LastIndexByte(value, '/'); slash >= 0 {
+		dir, prefix = value[:slash+1], value[slash+1:]
+	}
+
+	lookup := util.ExpandTilde(dir)
+	if lookup == "" {
+		lookup = "."
+	}
+
+	entries, err := os.ReadDir(lookup)
+	if err != nil {
+		return nil
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		// hidden files only show up once a dot has been typed
+		if strings.HasPrefix(name, ".") && !strings.HasPrefix(prefix, ".") {
+			continue
+		}
+
+		full := filepath.Join(lookup, name)
+		info, err := os.Stat(full) // follows symlinks to directories
+		if err != nil {
+			continue
+		}
+
+		switch {
+		case info.IsDir():
+			paths = append(paths, dir+name+"/")
+		case !executablesOnly || isExecutable(full):
+			paths = append(paths, dir+name)
+		}
+	}
+
+	sort.Strings(paths)
+	return paths
+}
+
+// completeVariable completes a variable name typed after before + "$"
+func completeVariable(before, prefix string, braced bool) []lineedit.Candidate {
+	matches := map[string]bool{}
+	for _, name := range util.VariableNames() {
+		if strings.HasPrefix(name, prefix) {
+			matches[name] = true
+		}
+	}
+
+	var candidates []lineedit.Candidate
+	for _, c := range sortedCandidates(matches) {
+		text := before + "$" + c.Text
+		if braced {
+			text = before + "${" + c.Text + "}"
+		}
+		candidates = append(candidates, lineedit.Candidate{Text: text, Display: c.Text})
+	}
+	return candidates
+}
+
+func sortedCandidates(names map[string]bool) []lineedit.Candidate {
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	candidates := make([]lineedit.Candidate, len(sorted))
+	for i, name := range sorted {
+		candidates[i] = lineedit.Candidate{Text: name}
+	}
+	return candidates
+}
+
+func suffixIfDir(path string) string {
+	if strings.HasSuffix(path, "/") {
+		return "/"
+	}
+	return ""
+}
+
+func isExecutable(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir() && info.Mode()&0111 != 0
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"simple_sh/internal/executor"
+	"simple_sh/internal/jobs"
+	"simple_sh/internal/lineedit"
+	"simple_sh/internal/util"
+	"strings"
+)
+
+var interactive bool  // commands are typed on a terminal
+var scriptName string // where commands come from when not interactive, for error messages
+var lineNumber int    // line of the script being run
+
+// lineReader is where the shell reads its commands from: the line editor on a
+// terminal, or a plainReader for a script, a -c string or piped input.
+type lineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+// plainReader reads lines from a file or a string without any editing or
+// prompt, the way scripts are read.
+type plainReader struct {
+	r io.ByteReader
+}
+
+func (p *plainReader) ReadLine(prompt string) (string, error) {
+	var line strings.Builder
+	for {
+		ch, err := p.r.ReadByte()
+		if err == io.EOF && line.Len() > 0 {
+			err = nil // a last line without a newline still runs, EOF comes next time
+			ch = '\n'
+		}
+		if err != nil {
+			return "", err
+		}
+		if ch == '\n' {
+			return line.String(), nil
+		}
+		line.WriteByte(ch)
+	}
+}
+
+// unbufferedReader reads the shell's stdin a byte at a time, so that nothing
+// past the current line is taken away from the commands it runs:
+// printf 'cat\nhello\n' | simple_sh gives hello to cat.
+type unbufferedReader struct {
+	f *os.File
+}
+
+func (u unbufferedReader) ReadByte() (byte, error) {
+	var b [1]byte
+	for {
+		n, err := u.f.Read(b[:])
+		if n == 1 {
+			return b[0], nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// openInput works out from the command line where commands are read from:
+//
+//	simple_sh                          stdin, interactive on a terminal
+//	simple_sh script [args...]         a script file, also used by #!
+//	simple_sh -c commands [name [args...]]
+//
+// and sets $0 and the positional parameters accordingly. On error it returns
+// the exit status to use as well.
+func openInput(args []string) (lineReader, int, error) {
+	name, args := args[0], args[1:]
+
+	switch {
+	case len(args) > 0 && args[0] == "-c":
+		if len(args) < 2 {
+			return nil, 2, fmt.Errorf("-c: option requires an argument")
+		}
+		scriptName = name + ": -c"
+		if len(args) > 2 {
+			util.SetPositionalArgs(args[2], args[3:])
+		} else {
+			util.SetPositionalArgs(name, nil)
+		}
+		return &plainReader{r: bufio.NewReader(strings.NewReader(args[1]))}, 0, nil
+
+	case len(args) > 0:
+		f, err := os.Open(args[0])
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil, 127, fmt.Errorf("%s: No such file or directory", args[0])
+			}
+			return nil, 126, fmt.Errorf("%s: %v", args[0], err)
+		}
+		scriptName = args[0]
+		util.SetPositionalArgs(args[0], args[1:])
+		return &plainReader{r: bufio.NewReader(f)}, 0, nil
+	}
+
+	util.SetPositionalArgs(name, nil)
+
+	// On a terminal lines are read through the line editor, otherwise
+	// (input piped in) they are read as they come, like a script
+	if !lineedit.IsTerminal(os.Stdin) {
+		scriptName = name
+		return &plainReader{r: unbufferedReader{os.Stdin}}, 0, nil
+	}
+
+	interactive = true
+	executor.Interactive = true
+	editor := lineedit.New(os.Stdin, os.Stderr, util.History)
+	editor.Complete = completeLine
+	jobs.InitJobControl(os.Stdin)
+	return editor, 0, nil
+}
+
+// errorPrefix returns what goes before an error message: nothing at the
+// prompt, the script and the line number otherwise, eg. "test.sh: line 3: "
+func errorPrefix() string {
+	if interactive {
+		return ""
+	}
+	return fmt.Sprintf("%s: line %d: ", scriptName, lineNumber)
+}
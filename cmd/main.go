@@ -1,226 +1,625 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"simple_sh/internal/executor"
 	"simple_sh/internal/jobs"
+	"simple_sh/internal/lineedit"
 	"simple_sh/internal/parser"
 	"simple_sh/internal/util"
+	"sort"
+	"strconv"
 	"strings"
+	"syscall"
 )
 
-var builtins = map[string]func(args []string) {
-	"cd":     builtinCd,
-	"help":   builtinHelp,
-	"exit":   builtinExit,
-	"pwd":    builtinPwd,
-	"clear":  builtinClear,
-	"echo":   builtinEcho,
-	"export": builtinExport,
-	"unset":  builtinUnset,
-	"jobs":   builtinJobs,
+var builtins = map[string]executor.Builtin{
+	"cd":       builtinCd,
+	"help":     builtinHelp,
+	"exit":     builtinExit,
+	"pwd":      builtinPwd,
+	"clear":    builtinClear,
+	"echo":     builtinEcho,
+	"export":   builtinExport,
+	"unset":    builtinUnset,
+	"jobs":     builtinJobs,
+	"fg":       builtinFg,
+	"bg":       builtinBg,
+	"kill":     builtinKill,
+	"wait":     builtinWait,
+	"disown":   builtinDisown,
+	"break":    builtinBreak,
+	"continue": builtinContinue,
+	"return":   builtinReturn,
+	"local":    builtinLocal,
+	"set":      builtinSet,
+	"shopt":    builtinShopt,
 }
 
 func main() {
+	executor.Builtins = builtins
+	util.CommandSubstitution = executor.CaptureOutput
+
+	input, status, err := openInput(os.Args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "simple_sh:", err)
+		os.Exit(status)
+	}
+	executor.LoadState()
+
 	// Setup signal handlers and load history
-	util.SetupSignalHandlers()
-	util.LoadHistory()
-	
-	reader := bufio.NewReader(os.Stdin)
-	fmt.Fprintln(os.Stderr, "Welcome to Simple Shell!")
-	fmt.Fprintln(os.Stderr, "Type 'help' for available commands")
+	if interactive {
+		util.SetupSignalHandlers()
+		util.LoadHistory()
+
+		fmt.Fprintln(os.Stderr, "Welcome to Simple Shell!")
+		fmt.Fprintln(os.Stderr, "Type 'help' for available commands")
+	}
+
+	var pending string // lines of a command that is not complete yet
 
 	for {
-		fmt.Fprint(os.Stderr, "shell> ")
+		prompt := "shell> "
+		if pending != "" {
+			prompt = "> " // continuation of an unfinished command
+		} else {
+			// Report the jobs that finished or stopped while the last command
+			// ran. A script only forgets the finished ones.
+			notices := io.Discard
+			if interactive {
+				notices = os.Stderr
+			}
+			jobs.NotifyJobChanges(notices)
+		}
 
-		input, err := reader.ReadString('\n')
+		line, err := input.ReadLine(prompt)
+		if err == lineedit.ErrInterrupted {
+			// Ctrl-C drops an unfinished command too, and sets the status
+			// of a command killed by SIGINT
+			pending = ""
+			util.SetExitStatus(130)
+			continue
+		}
+
+		// At the end of the input the shell exits with the last command's status
+		if err == io.EOF {
+			if pending != "" {
+				runInput(pending, true) // reports what is missing
+			}
+			os.Exit(util.ExitStatus())
+		}
 		if err != nil {
-			fmt.Println("Error reading input:", err)
-			return
+			fmt.Fprintln(os.Stderr, "Error reading input:", err)
+			os.Exit(1)
 		}
 
-		input = strings.TrimSpace(input)
+		lineNumber++
 
-		if input == "" {
-			continue
+		// Save to history, as typed so recalling it brings back the original line
+		if interactive && strings.TrimSpace(line) != "" {
+			util.SaveToHistory(strings.TrimSpace(line))
 		}
 
-		// Remove comments
-		input = util.RemoveComments(input)
-		if input == "" {
-			continue
+		if pending != "" {
+			line = pending + "\n" + line
 		}
+		if runInput(line, false) {
+			pending = ""
+		} else {
+			pending = line
+		}
+	}
+}
 
-		// Validate command
-		if err := util.ValidateCommand(input); err != nil {
-			fmt.Println("Error:", err)
-			continue
+// runInput parses and runs the lines read so far. When they stop in the
+// middle of a command, like an if without its fi, nothing runs and it
+// returns false so the caller reads more lines, unless the input is over.
+// A syntax error sets the exit status to 2, like in bash.
+func runInput(input string, atEOF bool) bool {
+	if strings.TrimSpace(input) == "" {
+		return true
+	}
+
+	// Parse the command list
+	list, err := parser.Parse(input)
+	if err != nil {
+		var parseErr *parser.ParseError
+		if errors.As(err, &parseErr) && parseErr.Incomplete && !atEOF {
+			return false
+		}
+		fmt.Fprintf(os.Stderr, "%sParse error: %v\n", errorPrefix(), err)
+		util.SetExitStatus(2)
+		return true
+	}
+
+	executor.RunList(list)
+	return true
+}
+
+func builtinJobs(ctx *executor.Context, args []string) int {
+	jobs.ListJobs(ctx.Stdout)
+	return 0
+}
+
+// fg [%n] brings a job to the foreground, continuing it if it was stopped
+func builtinFg(ctx *executor.Context, args []string) int {
+	job, err := jobs.FindJob(jobArg(args))
+	if err != nil {
+		fmt.Fprintln(ctx.Stderr, "fg:", err)
+		return 1
+	}
+	return jobs.Foreground(job, ctx.Stdout, ctx.Stderr)
+}
+
+// bg [%n] continues a stopped job in the background
+func builtinBg(ctx *executor.Context, args []string) int {
+	job, err := jobs.FindJob(jobArg(args))
+	if err == nil {
+		err = jobs.Background(job, ctx.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(ctx.Stderr, "bg:", err)
+		return 1
+	}
+	return 0
+}
+
+// kill [-SIG | -s SIG | -l] %n|pid... sends a signal, SIGTERM by default,
+// to jobs and processes
+func builtinKill(ctx *executor.Context, args []string) int {
+	args = args[1:]
+	sig := syscall.SIGTERM
+
+	if len(args) > 0 && args[0] == "-l" {
+		for _, line := range jobs.SignalList() {
+			fmt.Fprintln(ctx.Stdout, line)
+		}
+		return 0
+	}
+
+	var sigName string
+	switch {
+	case len(args) > 1 && args[0] == "-s":
+		sigName, args = args[1], args[2:]
+	case len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-':
+		sigName, args = args[0][1:], args[1:]
+	}
+	if sigName != "" {
+		var err error
+		if sig, err = jobs.ParseSignal(sigName); err != nil {
+			fmt.Fprintln(ctx.Stderr, "kill:", err)
+			return 1
 		}
+	}
 
-		// Expand variables
-		input = util.ExpandVariables(input)
+	if len(args) == 0 {
+		fmt.Fprintln(ctx.Stderr, "kill: usage: kill [-s sigspec | -sigspec] pid | jobspec ... or kill -l")
+		return 2
+	}
 
-		// Save to history
-		util.SaveToHistory(input)
+	status := 0
+	for _, target := range args {
+		if err := killTarget(target, sig); err != nil {
+			fmt.Fprintln(ctx.Stderr, "kill:", err)
+			status = 1
+		}
+	}
+	return status
+}
 
-		// Parse the command
-		cmd, err := parser.Parse(input)
+func killTarget(target string, sig syscall.Signal) error {
+	if strings.HasPrefix(target, "%") {
+		job, err := jobs.FindJob(target)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "Parse error:", err)
+			return err
+		}
+		return jobs.Kill(job, sig)
+	}
+
+	pid, err := strconv.Atoi(target)
+	if err != nil {
+		return fmt.Errorf("%s: arguments must be process or job IDs", target)
+	}
+	if err := syscall.Kill(pid, sig); err != nil {
+		return fmt.Errorf("(%d) - %v", pid, err)
+	}
+	return nil
+}
+
+// wait [%n|pid...] waits for the given jobs, or for all of them, and returns
+// the status of the last one
+func builtinWait(ctx *executor.Context, args []string) int {
+	if len(args) < 2 {
+		return jobs.WaitAll()
+	}
+
+	status := 0
+	for _, target := range args[1:] {
+		if strings.HasPrefix(target, "%") {
+			job, err := jobs.FindJob(target)
+			if err != nil {
+				fmt.Fprintln(ctx.Stderr, "wait:", err)
+				status = 127
+				continue
+			}
+			status = jobs.Wait(job)
 			continue
 		}
 
-		// Handle redirection
-		stdin, stdout, stderr, err := util.SetupRedirection(cmd)
+		pid, err := strconv.Atoi(target)
 		if err != nil {
-			fmt.Fprintln(os.Stderr, "Redirection error:", err)
+			fmt.Fprintf(ctx.Stderr, "wait: `%s': not a pid or valid job spec\n", target)
+			status = 2
 			continue
 		}
+		if status, err = jobs.WaitPid(pid); err != nil {
+			fmt.Fprintln(ctx.Stderr, "wait:", err)
+		}
+	}
+	return status
+}
 
-		// Save original streams
-		oldStdin := os.Stdin
-		oldStdout := os.Stdout
-		oldStderr := os.Stderr
+// disown [%n...] removes jobs from the job table, leaving them running
+func builtinDisown(ctx *executor.Context, args []string) int {
+	specs := args[1:]
+	if len(specs) == 0 {
+		specs = []string{""} // the current job
+	}
 
-		// Apply redirections BEFORE executing
-		if stdin != nil {
-			os.Stdin = stdin
-		}
-		if stdout != nil {
-			os.Stdout = stdout
-		}
-		if stderr != nil {
-			os.Stderr = stderr
+	status := 0
+	for _, spec := range specs {
+		job, err := jobs.FindJob(spec)
+		if err != nil {
+			fmt.Fprintln(ctx.Stderr, "disown:", err)
+			status = 1
+			continue
 		}
+		jobs.Disown(job)
+	}
+	return status
+}
 
-		// Check if it's a builtin
-		if builtinFunc, exists := builtins[cmd.Args[0]]; exists {
-			builtinFunc(cmd.Args)
-		} else {
-			// Execute external command
-			err = jobs.ExecuteCommandWithJobs(cmd.Args)
-			if err != nil {
-				fmt.Fprintln(os.Stderr, "Execution error:", err)
-			}
+// break [n] leaves the n innermost loops, 1 by default
+func builtinBreak(ctx *executor.Context, args []string) int {
+	return loopControl(ctx, args, executor.BreakLoops)
+}
+
+// continue [n] goes on with the next iteration of the n-th enclosing loop
+func builtinContinue(ctx *executor.Context, args []string) int {
+	return loopControl(ctx, args, executor.ContinueLoops)
+}
+
+// return [n] leaves the running function with status n, by default the
+// status of the last command
+func builtinReturn(ctx *executor.Context, args []string) int {
+	status := util.ExitStatus()
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Fprintf(ctx.Stderr, "return: %s: numeric argument required\n", args[1])
+			n = 2
 		}
+		status = n & 0xff
+	}
 
-		// Restore streams IMMEDIATELY
-		util.RestoreStandardStreams(oldStdin, oldStdout, oldStderr)
+	if err := executor.ReturnFromFunction(status); err != nil {
+		fmt.Fprintln(ctx.Stderr, "return:", err)
+		return 1
+	}
+	return status
+}
 
-		// Close files
-		if stdin != nil {
-			stdin.Close()
+// local NAME[=value]... creates variables only visible until the running
+// function returns, and to the functions it calls
+func builtinLocal(ctx *executor.Context, args []string) int {
+	status := 0
+	for _, arg := range args[1:] {
+		name, value, hasValue := strings.Cut(arg, "=")
+		if !parser.IsName(name) {
+			fmt.Fprintf(ctx.Stderr, "local: `%s': not a valid identifier\n", arg)
+			status = 1
+			continue
+		}
+		if err := util.MakeLocal(name); err != nil {
+			fmt.Fprintln(ctx.Stderr, "local:", err)
+			return 1
 		}
-		if stdout != nil {
-			stdout.Close()
+		if hasValue {
+			util.SetVariable(name, value)
 		}
-		if stderr != nil {
-			stderr.Close()
+	}
+	return status
+}
+
+func loopControl(ctx *executor.Context, args []string, jump func(n int) error) int {
+	n := 1
+	if len(args) > 1 {
+		var err error
+		n, err = strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Fprintf(ctx.Stderr, "%s: %s: numeric argument required\n", args[0], args[1])
+			return 1
 		}
+		if n < 1 {
+			fmt.Fprintf(ctx.Stderr, "%s: %d: loop count out of range\n", args[0], n)
+			return 1
+		}
+	}
 
-		// Clean up finished jobs
-		jobs.RemoveCompletedJobs()
+	if err := jump(n); err != nil {
+		fmt.Fprintf(ctx.Stderr, "%s: %v\n", args[0], err)
 	}
+	return 0
 }
 
-func builtinJobs(args []string) {
-	jobs.ListJobs()
+// jobArg returns the jobspec given to fg or bg, "" for the current job
+func jobArg(args []string) string {
+	if len(args) > 1 {
+		return args[1]
+	}
+	return ""
 }
 
-func builtinExit(args []string) {
-	fmt.Println("Goodbye!")
-	os.Exit(0)
+// exit [n] exits with n, or with the status of the last command
+func builtinExit(ctx *executor.Context, args []string) int {
+	status := util.ExitStatus()
+
+	if len(args) > 1 {
+		n, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Fprintln(ctx.Stderr, "exit: numeric argument required:", args[1])
+			return 2
+		}
+		status = n & 0xff // exit statuses are a single byte
+	}
+
+	if executor.ExitSubshell(status) {
+		return status
+	}
+
+	if interactive {
+		fmt.Fprintln(ctx.Stdout, "Goodbye!")
+	}
+	os.Exit(status)
+	return status
 }
 
-func builtinCd(args []string) {
+func builtinCd(ctx *executor.Context, args []string) int {
 	var path string
 
 	if len(args) < 2 {
-		homeDir, err := os.UserHomeDir()
-		if err != nil {
-			fmt.Println("cd: error getting home directory:", err)
-			return
+		path = ctx.Getenv("HOME")
+		if path == "" {
+			fmt.Fprintln(ctx.Stderr, "cd: HOME not set")
+			return 1
 		}
-		path = homeDir
 	} else {
-		// Expand tilde
-		path = util.ExpandTilde(args[1])
+		path = args[1]
 	}
 
 	err := os.Chdir(path)
 	if err != nil {
-		fmt.Println("cd:", err)
+		fmt.Fprintln(ctx.Stderr, "cd:", err)
+		return 1
 	}
+	return 0
 }
 
-func builtinPwd(args []string) {
-	workingDir, err := os.Getwd()
-	if err != nil {
-		fmt.Println("pwd:", err)
-	} else {
-		fmt.Println(workingDir)
+func builtinPwd(ctx *executor.Context, args []string) int {
+	if ctx.Dir == "" {
+		fmt.Fprintln(ctx.Stderr, "pwd: cannot determine the working directory")
+		return 1
 	}
+	fmt.Fprintln(ctx.Stdout, ctx.Dir)
+	return 0
 }
 
-func builtinEcho(args []string) {
+func builtinEcho(ctx *executor.Context, args []string) int {
+	var err error
 	if len(args) > 1 {
 		output := strings.Join(args[1:], " ")
-		fmt.Println(output)
+		_, err = fmt.Fprintln(ctx.Stdout, output)
 	} else {
-		fmt.Println()
+		_, err = fmt.Fprintln(ctx.Stdout)
 	}
+	// eg. echo hi >&-
+	if err != nil {
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			err = pathErr.Err
+		}
+		fmt.Fprintln(ctx.Stderr, "echo: write error:", err)
+		return 1
+	}
+	return 0
 }
 
-func builtinClear(args []string) {
-	fmt.Print("\033[H\033[2J")
+func builtinClear(ctx *executor.Context, args []string) int {
+	fmt.Fprint(ctx.Stdout, "\033[H\033[2J")
+	return 0
 }
 
-func builtinExport(args []string) {
+func builtinExport(ctx *executor.Context, args []string) int {
 	if len(args) < 2 {
-		fmt.Println("export: usage: export VAR=value")
-		return
+		fmt.Fprintln(ctx.Stderr, "export: usage: export VAR=value")
+		return 2
 	}
 
 	assignment := strings.Join(args[1:], " ")
 	parts := strings.SplitN(assignment, "=", 2)
 
-	if len(parts) != 2 {
-		fmt.Println("export: invalid format, use VAR=value")
-		return
+	varName := strings.TrimSpace(parts[0])
+	if !parser.IsName(varName) {
+		fmt.Fprintln(ctx.Stderr, "export: invalid format, use VAR=value")
+		return 1
 	}
 
-	varName := strings.TrimSpace(parts[0])
-	varValue := strings.TrimSpace(parts[1])
-	
-	err := os.Setenv(varName, varValue)
-	if err != nil {
-		fmt.Println("export:", err)
+	// export VAR=value sets and exports, export VAR exports an existing variable
+	if len(parts) == 2 {
+		util.SetVariable(varName, strings.TrimSpace(parts[1]))
+	}
+	util.ExportVariable(varName)
+	return 0
+}
+
+// set [-f|+f] [-o|+o option] [--] [args...] turns options on (-) or off (+)
+// and replaces the positional parameters with args. Alone it lists the
+// variables.
+func builtinSet(ctx *executor.Context, args []string) int {
+	args = args[1:]
+	if len(args) == 0 {
+		printVariables(ctx.Stdout)
+		return 0
+	}
+
+	for len(args) > 0 {
+		arg := args[0]
+		if arg == "--" {
+			args = args[1:]
+			break
+		}
+		if len(arg) < 2 || arg[0] != '-' && arg[0] != '+' {
+			break
+		}
+		on := arg[0] == '-'
+		args = args[1:]
+
+		switch arg[1:] {
+		case "f":
+			util.SetSetOption("noglob", on)
+		case "o":
+			if len(args) == 0 {
+				printOptions(ctx.Stdout, util.SetOptionNames())
+				return 0
+			}
+			if err := util.SetSetOption(args[0], on); err != nil {
+				fmt.Fprintln(ctx.Stderr, "set:", err)
+				return 2
+			}
+			args = args[1:]
+		default:
+			fmt.Fprintf(ctx.Stderr, "set: %s: invalid option\n", arg)
+			fmt.Fprintln(ctx.Stderr, "set: usage: set [-f|+f] [-o|+o option] [--] [arg ...]")
+			return 2
+		}
+		if len(args) == 0 {
+			return 0 // only options, the positional parameters stay
+		}
+	}
+
+	util.SetArgs(args)
+	return 0
+}
+
+// printVariables lists every variable as name=value, sorted, quoting values
+// so the output can be read back by the shell
+func printVariables(w io.Writer) {
+	names := util.VariableNames()
+	sort.Strings(names)
+	for i, name := range names {
+		if i > 0 && name == names[i-1] {
+			continue
+		}
+		fmt.Fprintf(w, "%s=%s\n", name, quoteValue(util.GetVariable(name)))
 	}
 }
 
-func builtinUnset(args []string) {
+func quoteValue(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\n'\"\\$`*?[]{}()<>|&;#~") {
+		return value
+	}
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// shopt [-s|-u] [option...] turns options on (-s) or off (-u). Without
+// -s or -u it shows them, and fails if one of them is off.
+func builtinShopt(ctx *executor.Context, args []string) int {
+	args = args[1:]
+	mode := ""
+	if len(args) > 0 && (args[0] == "-s" || args[0] == "-u") {
+		mode, args = args[0], args[1:]
+	}
+
+	if len(args) == 0 {
+		if mode != "" {
+			fmt.Fprintln(ctx.Stderr, "shopt: usage: shopt [-s|-u] [optname ...]")
+			return 2
+		}
+		printOptions(ctx.Stdout, util.OptionNames())
+		return 0
+	}
+
+	status := 0
+	for _, name := range args {
+		var err error
+		switch mode {
+		case "":
+			if err = util.SetOption(name, util.Option(name)); err == nil {
+				printOptions(ctx.Stdout, []string{name})
+				if !util.Option(name) {
+					status = 1
+				}
+			}
+		default:
+			err = util.SetOption(name, mode == "-s")
+		}
+		if err != nil {
+			fmt.Fprintln(ctx.Stderr, "shopt:", err)
+			status = 1
+		}
+	}
+	return status
+}
+
+func printOptions(w io.Writer, names []string) {
+	for _, name := range names {
+		state := "off"
+		if util.Option(name) {
+			state = "on"
+		}
+		fmt.Fprintf(w, "%-15s\t%s\n", name, state)
+	}
+}
+
+func builtinUnset(ctx *executor.Context, args []string) int {
 	if len(args) < 2 {
-		fmt.Println("unset: usage: unset VAR")
-		return
+		fmt.Fprintln(ctx.Stderr, "unset: usage: unset VAR")
+		return 2
 	}
-	
+
 	varName := args[1]
-	err := os.Unsetenv(varName)
+	err := util.UnsetVariable(varName)
 	if err != nil {
-		fmt.Println("unset:", err)
-	}
-}
-
-func builtinHelp(args []string) {
-	fmt.Println("Available builtin commands:")
-	fmt.Println("  cd [directory]     - Change directory")
-	fmt.Println("  pwd                - Print working directory")
-	fmt.Println("  echo [args...]     - Print arguments")
-	fmt.Println("  clear              - Clear the screen")
-	fmt.Println("  export VAR=value   - Set environment variable")
-	fmt.Println("  unset VAR          - Unset environment variable")
-	fmt.Println("  jobs               - List background jobs")
-	fmt.Println("  help               - Show this help message")
-	fmt.Println("  exit               - Exit the shell")
-}
\ No newline at end of file
+		fmt.Fprintln(ctx.Stderr, "unset:", err)
+		return 1
+	}
+	return 0
+}
+
+func builtinHelp(ctx *executor.Context, args []string) int {
+	fmt.Fprintln(ctx.Stdout, "Available builtin commands:")
+	fmt.Fprintln(ctx.Stdout, "  cd [directory]     - Change directory")
+	fmt.Fprintln(ctx.Stdout, "  pwd                - Print working directory")
+	fmt.Fprintln(ctx.Stdout, "  echo [args...]     - Print arguments")
+	fmt.Fprintln(ctx.Stdout, "  clear              - Clear the screen")
+	fmt.Fprintln(ctx.Stdout, "  export VAR[=value] - Set environment variable")
+	fmt.Fprintln(ctx.Stdout, "  unset VAR          - Unset environment variable")
+	fmt.Fprintln(ctx.Stdout, "  jobs               - List background jobs")
+	fmt.Fprintln(ctx.Stdout, "  fg [%n]            - Continue a job in the foreground")
+	fmt.Fprintln(ctx.Stdout, "  bg [%n]            - Continue a stopped job in the background")
+	fmt.Fprintln(ctx.Stdout, "  kill [-SIG] %n|pid - Send a signal to a job or process")
+	fmt.Fprintln(ctx.Stdout, "  wait [%n|pid]      - Wait for jobs to finish")
+	fmt.Fprintln(ctx.Stdout, "  disown [%n]        - Remove a job from the job table")
+	fmt.Fprintln(ctx.Stdout, "  break [n]          - Leave the n innermost loops")
+	fmt.Fprintln(ctx.Stdout, "  continue [n]       - Go on with the next iteration of a loop")
+	fmt.Fprintln(ctx.Stdout, "  return [n]         - Leave a function with status n")
+	fmt.Fprintln(ctx.Stdout, "  local VAR[=value]  - Create a variable local to a function")
+	fmt.Fprintln(ctx.Stdout, "  set [-f] [-- args] - Set options and positional parameters")
+	fmt.Fprintln(ctx.Stdout, "  shopt [-s|-u] opt  - Turn shell options on or off")
+	fmt.Fprintln(ctx.Stdout, "  help               - Show this help message")
+	fmt.Fprintln(ctx.Stdout, "  exit [n]           - Exit the shell with status n")
+	return 0
+}
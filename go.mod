@@ -2,4 +2,4 @@ module simple_sh
 
 go 1.24.3
 
-require golang.org/x/sys v0.41.0 // indirect
+require golang.org/x/sys v0.41.0
@@ -0,0 +1,535 @@
+// Package arith evaluates the integer expressions of the shell, as used by
+// $((expr)), the ((expr)) command and the arithmetic for loop:
+// for ((i = 0; i < n; i++)).
+//
+// Expressions use the C operators on 64-bit integers. Variables are shell
+// variables: an unset or empty one counts as 0, and the value of one that is
+// not a number is evaluated as an expression itself.
+package arith
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Env gives the evaluator access to the shell variables
+type Env interface {
+	Get(name string) string
+	Set(name, value string)
+}
+
+// maxDepth limits how deep variables referring to other expressions go,
+// eg. a=b b=a
+const maxDepth = 64
+
+// Eval evaluates expr, assigning the variables it modifies in env. An empty
+// expression is 0.
+func Eval(expr string, env Env) (int64, error) {
+	return eval(expr, env, 0)
+}
+
+func eval(expr string, env Env, depth int) (int64, error) {
+	if depth > maxDepth {
+		return 0, fmt.Errorf("%s: expression recursion level exceeded", expr)
+	}
+
+	p := &parser{src: expr, env: env, depth: depth}
+	if err := p.next(); err != nil {
+		return 0, err
+	}
+	if p.tok.kind == tokEOF {
+		return 0, nil
+	}
+
+	value, err := p.comma()
+	if err != nil {
+		return 0, err
+	}
+	if p.tok.kind != tokEOF {
+		return 0, p.errorf("syntax error in expression")
+	}
+	return value, nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokName
+	tokOperator
+)
+
+type token struct {
+	kind  tokenKind
+	text  string
+	start int // offset in the expression, for errors
+}
+
+// operators, longest first so the lexer matches += before +
+var operators = []string{
+	"**=", "<<=", ">>=",
+	"++", "--", "**", "<<", ">>",
+	"+=", "-=", "*=", "/=", "%=", "&=", "|=", "^=",
+	"<=", ">=", "==", "!=", "&&", "||",
+	"+", "-", "*", "/", "%", "<", ">", "=", "!", "~",
+	"&", "|", "^", "?", ":", "(", ")", ",",
+}
+
+// binaryPrecedence gives the binding power of each binary operator, higher
+// binds tighter
+var binaryPrecedence = map[string]int{
+	"||": 1,
+	"&&": 2,
+	"|":  3,
+	"^":  4,
+	"&":  5,
+	"==": 6, "!=": 6,
+	"<": 7, "<=": 7, ">": 7, ">=": 7,
+	"<<": 8, ">>": 8,
+	"+": 9, "-": 9,
+	"*": 10, "/": 10, "%": 10,
+	"**": 11,
+}
+
+// assignOperators maps each assignment operator to the binary operator it
+// applies, "" for plain assignment
+var assignOperators = map[string]string{
+	"=": "", "+=": "+", "-=": "-", "*=": "*", "/=": "/", "%=": "%",
+	"**=": "**", "<<=": "<<", ">>=": ">>", "&=": "&", "|=": "|", "^=": "^",
+}
+
+type parser struct {
+	src   string
+	pos   int
+	tok   token
+	env   Env
+	depth int
+
+	// skip is above 0 while evaluating an operand whose value is not used,
+	// like the right side of 0 && x++: nothing is assigned and no division
+	// by zero is reported there
+	skip int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if p.tok.kind != tokEOF {
+		msg += fmt.Sprintf(" (error token is \"%s\")", p.src[p.tok.start:])
+	}
+	return fmt.Errorf("%s: %s", strings.TrimSpace(p.src), msg)
+}
+
+// next reads the following token
+func (p *parser) next() error {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\n", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, start: start}
+		return nil
+	}
+
+	ch := p.src[p.pos]
+	switch {
+	case isDigit(ch):
+		for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || isLetter(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokNumber, text: p.src[start:p.pos], start: start}
+		return nil
+
+	case isLetter(ch):
+		for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || isLetter(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{kind: tokName, text: p.src[start:p.pos], start: start}
+		return nil
+	}
+
+	for _, op := range operators {
+		if strings.HasPrefix(p.src[p.pos:], op) {
+			p.pos += len(op)
+			p.tok = token{kind: tokOperator, text: op, start: start}
+			return nil
+		}
+	}
+
+	p.tok = token{kind: tokOperator, text: string(ch), start: start}
+	return p.errorf("syntax error: invalid arithmetic operator")
+}
+
+func (p *parser) isOperator(op string) bool {
+	return p.tok.kind == tokOperator && p.tok.text == op
+}
+
+// comma evaluates expressions separated by commas, the last one is the value
+func (p *parser) comma() (int64, error) {
+	value, err := p.assignment()
+	for err == nil && p.isOperator(",") {
+		if err = p.next(); err == nil {
+			value, err = p.assignment()
+		}
+	}
+	return value, err
+}
+
+// assignment evaluates name = value, name += value... or else a conditional
+// expression
+func (p *parser) assignment() (int64, error) {
+	if p.tok.kind == tokName {
+		saved, savedPos := p.tok, p.pos
+		name := p.tok.text
+		if err := p.next(); err != nil {
+			return 0, err
+		}
+
+		if op, ok := assignOperators[p.tok.text]; ok && p.tok.kind == tokOperator {
+			if err := p.next(); err != nil {
+				return 0, err
+			}
+			value, err := p.assignment()
+			if err != nil {
+				return 0, err
+			}
+			if op != "" {
+				current, err := p.variable(name)
+				if err != nil {
+					return 0, err
+				}
+				if value, err = p.apply(op, current, value); err != nil {
+					return 0, err
+				}
+			}
+			p.set(name, value)
+			return value, nil
+		}
+
+		// not an assignment, read the name again as an operand
+		p.tok, p.pos = saved, savedPos
+	}
+
+	return p.conditional()
+}
+
+// conditional evaluates cond ? a : b, where only the branch taken has side
+// effects
+func (p *parser) conditional() (int64, error) {
+	cond, err := p.binary(1)
+	if err != nil || !p.isOperator("?") {
+		return cond, err
+	}
+	if err := p.next(); err != nil {
+		return 0, err
+	}
+
+	if cond == 0 {
+		p.skip++
+	}
+	ifTrue, err := p.comma()
+	if cond == 0 {
+		p.skip--
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if !p.isOperator(":") {
+		return 0, p.errorf("syntax error: `:' expected for conditional expression")
+	}
+	if err := p.next(); err != nil {
+		return 0, err
+	}
+
+	if cond != 0 {
+		p.skip++
+	}
+	ifFalse, err := p.assignment()
+	if cond != 0 {
+		p.skip--
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if cond != 0 {
+		return ifTrue, nil
+	}
+	return ifFalse, nil
+}
+
+// binary evaluates operators of at least the given precedence, by
+// precedence climbing
+func (p *parser) binary(minPrecedence int) (int64, error) {
+	left, err := p.unary()
+	if err != nil {
+		return 0, err
+	}
+
+	for p.tok.kind == tokOperator {
+		op := p.tok.text
+		precedence, ok := binaryPrecedence[op]
+		if !ok || precedence < minPrecedence {
+			break
+		}
+		if err := p.next(); err != nil {
+			return 0, err
+		}
+
+		// && and || don't evaluate their right side when the left one
+		// decides the result
+		unused := op == "&&" && left == 0 || op == "||" && left != 0
+		if unused {
+			p.skip++
+		}
+		// ** is right associative: 2 ** 3 ** 2 is 2 ** 9
+		next := precedence + 1
+		if op == "**" {
+			next = precedence
+		}
+		right, err := p.binary(next)
+		if unused {
+			p.skip--
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		if left, err = p.apply(op, left, right); err != nil {
+			return 0, err
+		}
+	}
+
+	return left, nil
+}
+
+// apply computes a binary operation
+func (p *parser) apply(op string, left, right int64) (int64, error) {
+	switch op {
+	case "+":
+		return left + right, nil
+	case "-":
+		return left - right, nil
+	case "*":
+		return left * right, nil
+	case "**":
+		if right < 0 {
+			if p.skip > 0 {
+				return 0, nil
+			}
+			return 0, fmt.Errorf("%s: exponent less than 0", strings.TrimSpace(p.src))
+		}
+		return power(left, right), nil
+	case "/", "%":
+		if right == 0 {
+			if p.skip > 0 {
+				return 0, nil
+			}
+			return 0, fmt.Errorf("%s: division by 0", strings.TrimSpace(p.src))
+		}
+		if op == "/" {
+			return left / right, nil
+		}
+		return left % right, nil
+	case "<<":
+		return left << (uint64(right) & 63), nil
+	case ">>":
+		return left >> (uint64(right) & 63), nil
+	case "&":
+		return left & right, nil
+	case "|":
+		return left | right, nil
+	case "^":
+		return left ^ right, nil
+	case "<":
+		return boolValue(left < right), nil
+	case "<=":
+		return boolValue(left <= right), nil
+	case ">":
+		return boolValue(left > right), nil
+	case ">=":
+		return boolValue(left >= right), nil
+	case "==":
+		return boolValue(left == right), nil
+	case "!=":
+		return boolValue(left != right), nil
+	case "&&":
+		return boolValue(left != 0 && right != 0), nil
+	case "||":
+		return boolValue(left != 0 || right != 0), nil
+	}
+	return 0, p.errorf("syntax error: unknown operator %s", op)
+}
+
+// unary evaluates prefix operators: + - ! ~ ++ --
+func (p *parser) unary() (int64, error) {
+	if p.tok.kind != tokOperator {
+		return p.postfix()
+	}
+
+	op := p.tok.text
+	switch op {
+	case "++", "--":
+		if err := p.next(); err != nil {
+			return 0, err
+		}
+		if p.tok.kind != tokName {
+			return 0, p.errorf("syntax error: operand expected")
+		}
+		name := p.tok.text
+		if err := p.next(); err != nil {
+			return 0, err
+		}
+		return p.increment(name, op, true)
+
+	case "+", "-", "!", "~":
+		if err := p.next(); err != nil {
+			return 0, err
+		}
+		value, err := p.unary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "-":
+			return -value, nil
+		case "!":
+			return boolValue(value == 0), nil
+		case "~":
+			return ^value, nil
+		}
+		return value, nil
+	}
+
+	return p.postfix()
+}
+
+// postfix evaluates an operand, with ++ or -- after a variable
+func (p *parser) postfix() (int64, error) {
+	switch p.tok.kind {
+	case tokNumber:
+		value, err := parseNumber(p.tok.text)
+		if err != nil {
+			return 0, fmt.Errorf("%s: %v", strings.TrimSpace(p.src), err)
+		}
+		return value, p.next()
+
+	case tokName:
+		name := p.tok.text
+		if err := p.next(); err != nil {
+			return 0, err
+		}
+		if p.isOperator("++") || p.isOperator("--") {
+			op := p.tok.text
+			if err := p.next(); err != nil {
+				return 0, err
+			}
+			return p.increment(name, op, false)
+		}
+		return p.variable(name)
+	}
+
+	if p.isOperator("(") {
+		if err := p.next(); err != nil {
+			return 0, err
+		}
+		value, err := p.comma()
+		if err != nil {
+			return 0, err
+		}
+		if !p.isOperator(")") {
+			return 0, p.errorf("syntax error: missing `)'")
+		}
+		return value, p.next()
+	}
+
+	return 0, p.errorf("syntax error: operand expected")
+}
+
+// increment adds or subtracts one to a variable and returns the new value
+// for ++x, the old one for x++
+func (p *parser) increment(name, op string, prefix bool) (int64, error) {
+	old, err := p.variable(name)
+	if err != nil {
+		return 0, err
+	}
+
+	value := old + 1
+	if op == "--" {
+		value = old - 1
+	}
+	p.set(name, value)
+
+	if prefix {
+		return value, nil
+	}
+	return old, nil
+}
+
+// variable returns the value of a shell variable
+func (p *parser) variable(name string) (int64, error) {
+	value := strings.TrimSpace(p.env.Get(name))
+	if value == "" {
+		return 0, nil
+	}
+	if n, err := parseNumber(value); err == nil {
+		return n, nil
+	}
+	return eval(value, p.env, p.depth+1)
+}
+
+func (p *parser) set(name string, value int64) {
+	if p.skip == 0 {
+		p.env.Set(name, strconv.FormatInt(value, 10))
+	}
+}
+
+// parseNumber reads a decimal, 0x hexadecimal or 0 octal constant
+func parseNumber(s string) (int64, error) {
+	base := 10
+	digits := s
+	switch {
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X"):
+		base, digits = 16, s[2:]
+	case len(s) > 1 && s[0] == '0':
+		base, digits = 8, s[1:]
+	}
+
+	value, err := strconv.ParseUint(digits, base, 64)
+	if err != nil {
+		return 0, fmt.Errorf("value too great for base (error token is \"%s\")", s)
+	}
+	return int64(value), nil
+}
+
+// power computes base ** exp by squaring, wrapping around on overflow like
+// the other operators
+func power(base, exp int64) int64 {
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+	return result
+}
+
+func boolValue(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+func isLetter(ch byte) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_'
+}
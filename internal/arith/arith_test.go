@@ -0,0 +1,186 @@
+package arith
+
+import (
+	"maps"
+	"strings"
+	"testing"
+)
+
+// mapEnv holds the variables of a test
+type mapEnv map[string]string
+
+func (e mapEnv) Get(name string) string { return e[name] }
+func (e mapEnv) Set(name, value string) { e[name] = value }
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		expr string
+		want int64
+	}{
+		{"", 0},
+		{"42", 42},
+		{"0x1f", 31},
+		{"010", 8},
+
+		// precedence and associativity
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"7 / 2", 3},
+		{"-7 / 2", -3},
+		{"-7 % 3", -1},
+		{"1 << 4 | 1", 17},
+		{"6 & 3 ^ 1", 3},
+		{"1 + 2 == 3", 1},
+		{"3 > 2 && 2 > 1", 1},
+		{"0 || 0", 0},
+		{"1 < 2 == 1", 1},
+		{"!5", 0},
+		{"~0", -1},
+		{"-(2 + 3)", -5},
+		{"1, 2, 3", 3},
+
+		// ** binds tighter than unary minus on its left and is right
+		// associative
+		{"2 ** 10", 1024},
+		{"2 ** 0", 1},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", 4},
+		{"2 * 3 ** 2", 18},
+
+		// conditional expression
+		{"1 ? 2 : 3", 2},
+		{"0 ? 2 : 3", 3},
+		{"0 ? 2 : 0 ? 4 : 5", 5},
+
+		// variables: unset is 0, a non-number is an expression itself
+		{"unset + 1", 1},
+		{"x * 2", 10},
+		{"expr + 1", 7},
+	}
+
+	for _, tt := range tests {
+		env := mapEnv{"x": "5", "expr": "x + 1"}
+		got, err := Eval(tt.expr, env)
+		if err != nil {
+			t.Errorf("Eval(%q): unexpected error: %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%q) = %d, want %d", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestEvalAssignments(t *testing.T) {
+	tests := []struct {
+		expr string
+		want int64
+		vars map[string]string // the variables afterwards
+	}{
+		{"x = 3", 3, map[string]string{"x": "3"}},
+		{"a = b = 4", 4, map[string]string{"a": "4", "b": "4"}},
+		{"x += 2", 7, map[string]string{"x": "7"}},
+		{"x -= 2", 3, map[string]string{"x": "3"}},
+		{"x *= 3", 15, map[string]string{"x": "15"}},
+		{"x /= 2", 2, map[string]string{"x": "2"}},
+		{"x %= 3", 2, map[string]string{"x": "2"}},
+		{"x **= 2", 25, map[string]string{"x": "25"}},
+		{"x <<= 1", 10, map[string]string{"x": "10"}},
+		{"x >>= 1", 2, map[string]string{"x": "2"}},
+		{"x &= 4", 4, map[string]string{"x": "4"}},
+		{"x |= 2", 7, map[string]string{"x": "7"}},
+		{"x ^= 1", 4, map[string]string{"x": "4"}},
+
+		{"x++", 5, map[string]string{"x": "6"}},
+		{"x--", 5, map[string]string{"x": "4"}},
+		{"++x", 6, map[string]string{"x": "6"}},
+		{"--x", 4, map[string]string{"x": "4"}},
+		{"n++", 0, map[string]string{"n": "1"}},
+		{"x++ + x", 11, map[string]string{"x": "6"}},
+	}
+
+	for _, tt := range tests {
+		env := mapEnv{"x": "5"}
+		got, err := Eval(tt.expr, env)
+		if err != nil {
+			t.Errorf("Eval(%q): unexpected error: %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%q) = %d, want %d", tt.expr, got, tt.want)
+		}
+		for name, value := range tt.vars {
+			if env[name] != value {
+				t.Errorf("after Eval(%q): %s = %q, want %q", tt.expr, name, env[name], value)
+			}
+		}
+	}
+}
+
+// The side of &&, || or ?: that is not evaluated assigns nothing and cannot
+// fail
+func TestEvalShortCircuit(t *testing.T) {
+	tests := []struct {
+		expr string
+		want int64
+	}{
+		{"0 && (x = 1)", 0},
+		{"1 || x++", 1},
+		{"0 && 1 / 0", 0},
+		{"1 || 1 % 0", 1},
+		{"1 ? 2 : (x = 5)", 2},
+		{"0 ? x++ : 3", 3},
+		{"0 ? 1 / 0 : 3", 3},
+		{"1 ? 3 : 1 / 0", 3},
+	}
+
+	for _, tt := range tests {
+		env := mapEnv{}
+		got, err := Eval(tt.expr, env)
+		if err != nil {
+			t.Errorf("Eval(%q): unexpected error: %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Eval(%q) = %d, want %d", tt.expr, got, tt.want)
+		}
+		if len(env) != 0 {
+			t.Errorf("Eval(%q) assigned %v", tt.expr, env)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string // part of the error message
+	}{
+		{"1 / 0", "division by 0"},
+		{"1 % 0", "division by 0"},
+		{"x /= 0", "division by 0"},
+		{"2 ** -1", "exponent less than 0"},
+		{"1 +", "operand expected"},
+		{"(1 + 2", "missing `)'"},
+		{"1 ? 2", "`:' expected"},
+		{"1 2", "syntax error"},
+		{"09", "value too great for base"},
+		{"loop", "recursion level exceeded"},
+	}
+
+	for _, tt := range tests {
+		env := mapEnv{"x": "5", "loop": "loop + 1"}
+		before := maps.Clone(env)
+		_, err := Eval(tt.expr, env)
+		if err == nil {
+			t.Errorf("Eval(%q): no error, want one with %q", tt.expr, tt.want)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Eval(%q): error %q, want one with %q", tt.expr, err, tt.want)
+		}
+		if !maps.Equal(env, before) {
+			t.Errorf("Eval(%q) changed the variables to %v", tt.expr, env)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package executor
+
+import (
+	"io"
+	"os"
+	"simple_sh/internal/util"
+	"strings"
+)
+
+// Context is what a builtin runs with: the standard streams given by its
+// redirections or by a command substitution, and the environment and
+// working directory of the shell. Builtins use its streams rather than
+// os.Stdout and friends, which stay the shell's own:
+//
+//	x=$(pwd); echo hi > out.txt
+type Context struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	Env    []string // the exported variables, as NAME=value
+	Dir    string   // the working directory, "" when it cannot be found
+}
+
+// Getenv returns the value of an exported variable, "" when it is not set
+func (ctx *Context) Getenv(name string) string {
+	for _, entry := range ctx.Env {
+		if value, ok := strings.CutPrefix(entry, name+"="); ok {
+			return value
+		}
+	}
+	return ""
+}
+
+// Builtin is a command implemented by the shell itself. It returns its exit
+// status.
+type Builtin func(ctx *Context, args []string) int
+
+// Builtins holds the builtins by name. main registers them at startup.
+var Builtins map[string]Builtin
+
+// newContext gives a builtin the standard streams of a table
+func newContext(table *util.FdTable) *Context {
+	dir, _ := os.Getwd()
+	return &Context{
+		Stdin:  openOrClosed(table.Get(0)),
+		Stdout: openOrClosed(table.Get(1)),
+		Stderr: openOrClosed(table.Get(2)),
+		Env:    os.Environ(),
+		Dir:    dir,
+	}
+}
+
+// closedFile stands for a standard stream closed with >&-: reading or
+// writing it fails
+var closedFile = func() *os.File {
+	f, _ := os.Open(os.DevNull)
+	f.Close()
+	return f
+}()
+
+func openOrClosed(f *os.File) *os.File {
+	if f == nil {
+		return closedFile
+	}
+	return f
+}
@@ -0,0 +1,253 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"simple_sh/internal/parser"
+	"simple_sh/internal/util"
+)
+
+// loopDepth is the number of loops running. break and continue set
+// breaking or continuing to the number of loops they act on, and every list
+// stops running commands until the loops have counted them down.
+var loopDepth int
+var breaking, continuing int
+
+// interrupting is set when Ctrl-C reaches the shell while it runs commands
+// itself, as in for ((;;)); do x=1; done. See RunList.
+var interrupting bool
+
+// BreakLoops implements break n: leave the n innermost loops
+func BreakLoops(n int) error {
+	if loopDepth == 0 {
+		return fmt.Errorf("only meaningful in a `for', `while', or `until' loop")
+	}
+	breaking = min(n, loopDepth)
+	return nil
+}
+
+// ContinueLoops implements continue n: go on with the next iteration of the
+// n-th enclosing loop
+func ContinueLoops(n int) error {
+	if loopDepth == 0 {
+		return fmt.Errorf("only meaningful in a `for', `while', or `until' loop")
+	}
+	continuing = min(n, loopDepth)
+	return nil
+}
+
+// jumping reports whether a break, continue, return, exit or Ctrl-C is
+// leaving the current list
+func jumping() bool {
+	return breaking > 0 || continuing > 0 || returning || exiting || interrupting
+}
+
+// endOfIteration counts down a pending break or continue at the end of an
+// iteration of a loop, and reports whether the loop must stop.
+func endOfIteration() bool {
+	if returning || exiting || interrupting {
+		return true // cleared once out of the function, the subshell or the command line
+	}
+	if breaking > 0 {
+		breaking--
+		return true
+	}
+	if continuing > 0 {
+		continuing--
+		return continuing > 0 // continue 2 also leaves this loop
+	}
+	return false
+}
+
+// runIf runs the body of the first branch whose condition succeeds, or the
+// else part. With no branch taken the status is 0.
+func runIf(clause *parser.IfClause) int {
+	for _, branch := range clause.Branches {
+		status := RunList(branch.Cond)
+		if jumping() {
+			return status
+		}
+		if status == 0 {
+			return RunList(branch.Body)
+		}
+	}
+
+	if clause.Else != nil {
+		return RunList(clause.Else)
+	}
+	return 0
+}
+
+// runCase runs the body of the first item with a pattern matching the word.
+// Its terminator decides what happens next: ;; ends the case, ;& runs the
+// next body as well and ;;& goes on testing the next patterns.
+func runCase(clause *parser.CaseClause) int {
+	word, err := util.ExpandWord(clause.Word)
+	if err != nil {
+		return expansionFailed(err)
+	}
+
+	status := 0
+	fallingThrough := false
+	for _, item := range clause.Items {
+		if !fallingThrough {
+			matched, err := matchesAny(item.Patterns, word)
+			if err != nil {
+				return expansionFailed(err)
+			}
+			if !matched {
+				continue
+			}
+		}
+
+		status = 0
+		if len(item.Body.Items) > 0 {
+			status = RunList(item.Body)
+		}
+		if jumping() {
+			return status
+		}
+
+		switch item.Terminator {
+		case ";&":
+			fallingThrough = true
+		case ";;&":
+			fallingThrough = false
+		default:
+			return status
+		}
+	}
+	return status
+}
+
+func matchesAny(patterns []*parser.Word, word string) (bool, error) {
+	for _, pattern := range patterns {
+		expanded, err := util.ExpandPattern(pattern)
+		if err != nil {
+			return false, err
+		}
+		if util.MatchPattern(expanded, word) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// runWhile runs a while or until loop. Its status is the one of the last
+// iteration, 0 when the body never ran.
+func runWhile(clause *parser.WhileClause) int {
+	loopDepth++
+	defer func() { loopDepth-- }()
+
+	status := 0
+	for {
+		condStatus := RunList(clause.Cond)
+		if jumping() {
+			if endOfIteration() {
+				break
+			}
+			continue
+		}
+		if (condStatus == 0) == clause.Until {
+			break
+		}
+
+		status = RunList(clause.Body)
+		if endOfIteration() {
+			break
+		}
+	}
+	return status
+}
+
+// runFor runs the body of a for loop once for each word of its list, after
+// expansion and globbing, or of the positional parameters without a list
+func runFor(clause *parser.ForClause) int {
+	values := util.PositionalArgs()
+	if clause.In {
+		var err error
+		if values, err = util.ExpandWords(clause.Words); err != nil {
+			return expansionFailed(err)
+		}
+	}
+
+	loopDepth++
+	defer func() { loopDepth-- }()
+
+	status := 0
+	for _, value := range values {
+		util.SetVariable(clause.Name, value)
+		status = RunList(clause.Body)
+		if endOfIteration() {
+			break
+		}
+	}
+	return status
+}
+
+// runArithFor runs for ((init; cond; post)). An empty condition is true.
+func runArithFor(clause *parser.ArithForClause) int {
+	eval := func(expr *parser.Word) (int64, bool) {
+		value, err := util.ExpandArithmetic(expr)
+		if err != nil {
+			fmt.Fprintf(stderr(), "%s: %v\n", clause.Pos(), err)
+			return 0, false
+		}
+		return value, true
+	}
+
+	if _, ok := eval(clause.Init); !ok {
+		return 1
+	}
+
+	loopDepth++
+	defer func() { loopDepth-- }()
+
+	status := 0
+	for {
+		if cond, ok := eval(clause.Cond); !ok {
+			return 1
+		} else if cond == 0 && len(clause.Cond.Parts) > 0 {
+			break
+		}
+
+		status = RunList(clause.Body)
+		if endOfIteration() {
+			break
+		}
+
+		if _, ok := eval(clause.Post); !ok {
+			return 1
+		}
+	}
+	return status
+}
+
+// runArithCommand runs ((expr)), which succeeds when expr is not 0
+func runArithCommand(cmd *parser.ArithCommand) int {
+	value, err := util.ExpandArithmetic(cmd.Expr)
+	if err != nil {
+		return expansionFailed(err)
+	}
+	if value == 0 {
+		return 1
+	}
+	return 0
+}
+
+// Interactive is set when the shell reads commands from a terminal
+var Interactive bool
+
+// expansionFailed reports an error expanding the words of a command, which
+// then does not run. Like bash, a shell running a script exits, and so does
+// a subshell: sh -c '${y:?oops}; echo after' prints no "after".
+func expansionFailed(err error) int {
+	fmt.Fprintln(stderr(), err)
+	if ExitSubshell(1) {
+		return 1
+	}
+	if !Interactive {
+		os.Exit(1)
+	}
+	return 1
+}
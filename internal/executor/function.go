@@ -0,0 +1,62 @@
+package executor
+
+import (
+	"fmt"
+	"simple_sh/internal/parser"
+	"simple_sh/internal/util"
+)
+
+// Functions holds the functions defined so far by name. A function hides a
+// builtin or an external command of the same name.
+var Functions = map[string]*parser.FunctionDef{}
+
+// maxCallDepth stops runaway recursion before it exhausts the Go stack
+const maxCallDepth = 1000
+
+var callDepth int
+
+// return sets returning, and like break every list stops running commands
+// until the function call is left
+var returning bool
+var returnStatus int
+
+// ReturnFromFunction implements return n: leave the running function with
+// status n
+func ReturnFromFunction(status int) error {
+	if callDepth == 0 {
+		return fmt.Errorf("can only `return' from a function or sourced script")
+	}
+	returning = true
+	returnStatus = status
+	return nil
+}
+
+// callFunction runs a function with args[0] as its name and the rest as its
+// positional parameters. Its status is the one of return, or of the last
+// command it ran.
+func callFunction(fn *parser.FunctionDef, args []string) int {
+	if callDepth >= maxCallDepth {
+		fmt.Fprintf(stderr(), "%s: maximum function nesting level exceeded (%d)\n", fn.Name, maxCallDepth)
+		return 1
+	}
+
+	callDepth++
+	util.PushScope(args[1:])
+
+	// the loops of the caller are out of reach of break and continue
+	callerLoops := loopDepth
+	loopDepth = 0
+
+	defer func() {
+		loopDepth = callerLoops
+		util.PopScope()
+		callDepth--
+	}()
+
+	status, _ := runInShell(fn.Body)
+	if returning {
+		returning = false
+		status = returnStatus
+	}
+	return status
+}
@@ -0,0 +1,409 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"simple_sh/internal/jobs"
+	"simple_sh/internal/parser"
+	"simple_sh/internal/util"
+	"syscall"
+)
+
+// listDepth is the number of lists running, 1 for the command line itself
+var listDepth int
+
+// RunList runs every item of the list in order and returns the status of the
+// last one, which is also what $? expands to afterwards.
+//
+// Ctrl-C received by the shell stops every list running, and the loops
+// around them, the way an exit leaves a subshell: the status is then 130.
+func RunList(list *parser.List) int {
+	if listDepth == 0 {
+		util.Interrupted() // a Ctrl-C from before this command line
+	}
+	listDepth++
+	defer func() { listDepth-- }()
+
+	status := util.ExitStatus()
+
+	for _, item := range list.Items {
+		if util.Interrupted() {
+			interrupting = true
+		}
+		if jumping() {
+			break // break or continue leaving the loop body
+		}
+		if item.Background && len(item.AndOr.Pipelines) > 1 {
+			status = runListInBackground(item)
+		} else {
+			status = runAndOr(item.AndOr, item.Background)
+		}
+	}
+
+	if interrupting {
+		status = 128 + int(syscall.SIGINT)
+		util.SetExitStatus(status)
+		if listDepth == 1 {
+			interrupting = false
+		}
+	}
+	return status
+}
+
+// runListInBackground runs a chain of pipelines ended by & as a single
+// job: a child shell runs the whole chain, as in sleep 2 && echo done &
+func runListInBackground(item *parser.ListItem) int {
+	cmd, err := childShell(item.Source, util.PositionalArgs())
+	if err != nil {
+		fmt.Fprintln(stderr(), "Execution error:", err)
+		util.SetExitStatus(1)
+		return 1
+	}
+	setFiles(cmd, files)
+
+	status, err := jobs.ExecutePipeline([]*exec.Cmd{cmd}, nil, true, item.Source, jobMessages())
+	if err != nil {
+		fmt.Fprintln(stderr(), "Execution error:", err)
+	} else {
+		util.SetLastBackgroundPid(jobs.LastBackgroundPID())
+	}
+	util.SetExitStatus(status)
+	return status
+}
+
+// runAndOr runs a chain of pipelines. A pipeline after && only runs if the
+// previous one succeeded, one after || only if it failed. background is
+// only set for a single pipeline: a longer chain ended by & goes to the
+// background as a whole, see runListInBackground.
+func runAndOr(andOr *parser.AndOr, background bool) int {
+	status := 0
+
+	for i, pipeline := range andOr.Pipelines {
+		if jumping() {
+			break
+		}
+		if i > 0 {
+			switch andOr.Operators[i-1] {
+			case "&&":
+				if status != 0 {
+					continue
+				}
+			case "||":
+				if status == 0 {
+					continue
+				}
+			}
+		}
+
+		status = RunPipeline(pipeline, background)
+		util.SetExitStatus(status)
+	}
+
+	return status
+}
+
+// RunPipeline runs a single pipeline and returns its exit status. A lone
+// builtin or compound command runs inside the shell itself, everything else
+// runs as stages connected by pipes, see runStages.
+func RunPipeline(pipeline *parser.Pipeline, background bool) int {
+	if len(pipeline.Commands) == 1 && !background {
+		if status, ok := runInShell(pipeline.Commands[0]); ok {
+			return status
+		}
+	}
+
+	// every word is expanded once, before anything runs: $((i++)) or
+	// $(cmd) must not be evaluated again when the command is started. The
+	// words of a compound command are expanded by the shell running it.
+	argv := make([][]string, len(pipeline.Commands))
+	for i, cmd := range pipeline.Commands {
+		if simple, ok := cmd.(*parser.SimpleCommand); ok {
+			args, err := util.ExpandWords(simple.Args)
+			if err != nil && (len(pipeline.Commands) > 1 || background) {
+				// only the stage fails, as it runs apart from the shell
+				fmt.Fprintln(stderr(), err)
+				return 1
+			}
+			if err != nil {
+				return expansionFailed(err)
+			}
+			argv[i] = args
+		}
+	}
+
+	if cmd, ok := pipeline.Commands[0].(*parser.SimpleCommand); ok && len(pipeline.Commands) == 1 {
+		args := argv[0]
+
+		if len(args) == 0 {
+			return runAssignments(cmd)
+		}
+
+		if fn, isFunction := Functions[args[0]]; isFunction && !background {
+			return runRedirected(cmd.Redirs, func() int {
+				return withAssignments(cmd.Assigns, func() int { return callFunction(fn, args) })
+			})
+		}
+
+		if builtinFunc, isBuiltin := Builtins[args[0]]; isBuiltin && !background {
+			return runRedirected(cmd.Redirs, func() int {
+				return withAssignments(cmd.Assigns, func() int { return builtinFunc(newContext(files), args) })
+			})
+		}
+	}
+
+	return runStages(pipeline, argv, background)
+}
+
+// runInShell runs a compound command or a function definition inside the
+// shell itself. It reports false for the commands it does not handle.
+func runInShell(cmd parser.Command) (int, bool) {
+	switch cmd := cmd.(type) {
+	case *parser.Subshell:
+		return runRedirected(cmd.Redirs, func() int {
+			return runSubshell(func() int { return RunList(cmd.Body) })
+		}), true
+	case *parser.Group:
+		return runRedirected(cmd.Redirs, func() int { return RunList(cmd.Body) }), true
+	case *parser.IfClause:
+		return runRedirected(cmd.Redirs, func() int { return runIf(cmd) }), true
+	case *parser.WhileClause:
+		return runRedirected(cmd.Redirs, func() int { return runWhile(cmd) }), true
+	case *parser.ForClause:
+		return runRedirected(cmd.Redirs, func() int { return runFor(cmd) }), true
+	case *parser.ArithForClause:
+		return runRedirected(cmd.Redirs, func() int { return runArithFor(cmd) }), true
+	case *parser.CaseClause:
+		return runRedirected(cmd.Redirs, func() int { return runCase(cmd) }), true
+	case *parser.ArithCommand:
+		return runRedirected(cmd.Redirs, func() int { return runArithCommand(cmd) }), true
+	case *parser.FunctionDef:
+		Functions[cmd.Name] = cmd
+		return 0, true
+	}
+	return 0, false
+}
+
+// runAssignments handles a command made only of assignments and
+// redirections, eg: FOO=bar or > empty.txt
+func runAssignments(cmd *parser.SimpleCommand) int {
+	lastSubstitution = 0
+
+	redirected := util.NewFdTable(files.Files...)
+	if err := redirected.Redirect(cmd.Redirs); err != nil {
+		fmt.Fprintln(stderr(), "Redirection error:", err)
+		return 1
+	}
+	redirected.Close()
+
+	for _, assign := range cmd.Assigns {
+		value, err := util.ExpandWord(assign.Value)
+		if err != nil {
+			return expansionFailed(err)
+		}
+		util.SetVariable(assign.Name, value)
+	}
+	return lastSubstitution
+}
+
+// withAssignments runs a function or a builtin with the assignments before
+// it, as in FOO=1 f: the variables are exported while it runs, then put back
+func withAssignments(assigns []*parser.Assign, run func() int) int {
+	for _, assign := range assigns {
+		value, err := util.ExpandWord(assign.Value)
+		if err != nil {
+			return expansionFailed(err)
+		}
+		defer util.SetTemporarily(assign.Name, value)()
+	}
+	return run()
+}
+
+// runStages starts every stage of a pipeline, with the arguments in argv,
+// connecting the stdout of each stage to the stdin of the next one with an
+// OS pipe. Redirections of a stage take precedence over the pipe.
+//
+// Every stage is a process of a single job. Builtins, functions and
+// compound commands run in a child shell (see childShell), as they must not
+// change the shell running the pipeline: export FOO=1 | cat leaves FOO
+// unset. The status is the one of the last stage.
+func runStages(pipeline *parser.Pipeline, argv [][]string, background bool) int {
+	var cmds []*exec.Cmd
+	var parentFiles []*os.File // files the shell closes once the children own them
+
+	// the files opened by redirections are the shell's to close too, once
+	// the processes are started
+	var tables []*util.FdTable
+	defer func() {
+		for _, table := range tables {
+			table.Close()
+		}
+	}()
+
+	fail := func(status int, format string, args ...interface{}) int {
+		for _, f := range parentFiles {
+			f.Close()
+		}
+		fmt.Fprintf(stderr(), "Execution error: "+format+"\n", args...)
+		return status
+	}
+
+	var prevRead *os.File // read end of the pipe coming from the previous stage
+	lastEmpty := false    // the last stage has no command, its status is 0
+
+	stages := pipeline.Commands
+	for i, stage := range stages {
+		simple, isSimple := stage.(*parser.SimpleCommand)
+		args := argv[i]
+
+		stdin, stdout := files.Get(0), files.Get(1)
+		if prevRead != nil {
+			stdin = prevRead
+		}
+
+		if i < len(stages)-1 {
+			pipeRead, pipeWrite, err := os.Pipe()
+			if err != nil {
+				return fail(1, "failed to create pipe: %v", err)
+			}
+			parentFiles = append(parentFiles, pipeRead, pipeWrite)
+			stdout = pipeWrite
+			prevRead = pipeRead
+		}
+
+		// Per-stage redirections override the pipe ends
+		stageFiles := util.NewFdTable(append([]*os.File{stdin, stdout, files.Get(2)}, files.Files[3:]...)...)
+		if err := stageFiles.Redirect(parser.Redirects(stage)); err != nil {
+			return fail(1, "%v", err)
+		}
+		tables = append(tables, stageFiles)
+
+		// a stage made only of assignments and redirections, as in
+		// echo hi | > out.txt, runs nothing once its files are opened
+		if isSimple && len(args) == 0 {
+			lastEmpty = i == len(stages)-1
+			continue
+		}
+
+		var cmd *exec.Cmd
+		var err error
+		switch {
+		case !isSimple:
+			cmd, err = childShell(pipeline.Sources[i], util.PositionalArgs())
+		case Functions[args[0]] != nil || Builtins[args[0]] != nil:
+			cmd, err = childShell(`"$@"`, args)
+		default:
+			var path string
+			path, err = exec.LookPath(args[0]) // Searches your system's PATH for the executable
+			if err != nil {
+				if errors.Is(err, fs.ErrPermission) {
+					return fail(126, "permission denied: %s", args[0])
+				}
+				return fail(127, "command not found: %s", args[0])
+			}
+			cmd = exec.Command(path, args[1:]...)
+		}
+		if err != nil {
+			return fail(1, "%v", err)
+		}
+
+		// FOO=bar cmd puts FOO in the environment of cmd only
+		if isSimple && len(simple.Assigns) > 0 {
+			if cmd.Env == nil {
+				cmd.Env = os.Environ()
+			}
+			for _, assign := range simple.Assigns {
+				value, err := util.ExpandWord(assign.Value)
+				if err != nil {
+					return fail(1, "%v", err)
+				}
+				cmd.Env = append(cmd.Env, assign.Name+"="+value)
+			}
+		}
+
+		setFiles(cmd, stageFiles)
+		cmds = append(cmds, cmd)
+	}
+
+	if len(cmds) == 0 {
+		for _, f := range parentFiles {
+			f.Close()
+		}
+		return 0
+	}
+
+	status, err := jobs.ExecutePipeline(cmds, parentFiles, background, pipeline.Source, jobMessages())
+	if err != nil {
+		fmt.Fprintln(stderr(), "Execution error:", err)
+	} else if background {
+		util.SetLastBackgroundPid(jobs.LastBackgroundPID())
+	}
+	if err == nil && lastEmpty {
+		status = 0
+	}
+
+	// Ctrl-C only reaches the foreground job, not the shell: stop the
+	// loops running it too, or while true; do sleep 1; done never ends.
+	// At the prompt the rest of the command line is dropped as well.
+	switch {
+	case status != 128+int(syscall.SIGINT) || background:
+	case Interactive:
+		interrupting = true
+	case loopDepth > 0:
+		breaking = loopDepth
+	}
+	return status
+}
+
+// setFiles gives a process the descriptors of a table. A standard stream
+// closed with >&- is given as a nil *os.File, not left nil, which exec
+// would connect to the null device: the process starts with it closed.
+func setFiles(cmd *exec.Cmd, table *util.FdTable) {
+	cmd.Stdin = table.Get(0)
+	cmd.Stdout = table.Get(1)
+	cmd.Stderr = table.Get(2)
+	if len(table.Files) > 3 {
+		cmd.ExtraFiles = table.Files[3:]
+	}
+}
+
+// files holds the descriptors of the commands run by the shell: at first
+// its own standard streams, replaced for a while by the redirections of a
+// compound command or by a command substitution, along with the
+// descriptors from 3 up they open, eg. 3 in { cmd >&3; } 3> log.
+var files = util.NewFdTable(os.Stdin, os.Stdout, os.Stderr)
+
+// stderr is where the shell reports the errors of the commands it runs
+func stderr() io.Writer {
+	return openOrClosed(files.Get(2))
+}
+
+// jobMessages is where the messages about the jobs started go, like
+// "[1] 1234" for sleep 5 &: the terminal of an interactive shell, but
+// nowhere in a script or in a subshell, as in x=$(sleep 1 & echo hi)
+func jobMessages() io.Writer {
+	if !Interactive || subshellDepth > 0 {
+		return io.Discard
+	}
+	return stderr()
+}
+
+// runRedirected runs a builtin or a compound command with its redirections
+// applied to the descriptors in files, restoring them afterwards
+func runRedirected(redirs []*parser.Redirect, run func() int) int {
+	redirected := util.NewFdTable(files.Files...)
+	if err := redirected.Redirect(redirs); err != nil {
+		fmt.Fprintln(stderr(), "Redirection error:", err)
+		return 1
+	}
+	defer redirected.Close()
+
+	outer := files
+	files = redirected
+	defer func() { files = outer }()
+
+	return run()
+}
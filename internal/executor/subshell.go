@@ -0,0 +1,185 @@
+package executor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"simple_sh/internal/parser"
+	"simple_sh/internal/util"
+	"slices"
+	"strings"
+)
+
+// subshellDepth counts the subshells running inside the shell process, like
+// the body of a command substitution. exit leaves the innermost one instead
+// of the shell: like return it sets exiting, and every list stops running
+// commands until the subshell is left.
+var subshellDepth int
+var exiting bool
+var exitingStatus int
+
+// lastSubstitution is the status of the last command substitution, which is
+// also the status of a command made only of assignments: x=$(false)
+var lastSubstitution int
+
+// ExitSubshell implements exit n inside a subshell. It reports false when the
+// shell is not running one, and exit must end the shell itself.
+func ExitSubshell(status int) bool {
+	if subshellDepth == 0 {
+		return false
+	}
+	exiting = true
+	exitingStatus = status
+	return true
+}
+
+// runSubshell runs commands in a copy of the shell environment: the
+// variables they set, the functions they define and a change of directory
+// are undone when they are done.
+func runSubshell(run func() int) int {
+	variables := util.SaveVariables()
+	functions := maps.Clone(Functions)
+	dir, dirErr := os.Getwd()
+
+	callerLoops := loopDepth
+	loopDepth = 0
+	subshellDepth++
+
+	defer func() {
+		subshellDepth--
+		loopDepth = callerLoops
+		variables.Restore()
+		Functions = functions
+		if dirErr == nil {
+			os.Chdir(dir)
+		}
+	}()
+
+	status := run()
+	if exiting {
+		exiting = false
+		status = exitingStatus
+	}
+	if returning {
+		returning = false // return leaves the subshell, not the function around it
+		status = returnStatus
+	}
+	return status
+}
+
+// CaptureOutput runs the body of a command substitution in a subshell and
+// returns what it wrote on its standard output: a pipe read by the shell,
+// which replaces descriptor 1 in files meanwhile.
+func CaptureOutput(body *parser.List) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		fmt.Fprintln(stderr(), "command substitution:", err)
+		return ""
+	}
+
+	var output bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		io.Copy(&output, r)
+		close(done)
+	}()
+
+	outer := files
+	files = util.NewFdTable(outer.Files...)
+	files.Files[1] = w
+	status := runSubshell(func() int { return RunList(body) })
+	files = outer
+
+	// the copy ends once every writer is gone, including background
+	// commands started by the body
+	w.Close()
+	<-done
+	r.Close()
+
+	lastSubstitution = status
+	util.SetExitStatus(status)
+	return output.String()
+}
+
+// A subshell or another compound command in a pipeline or in the background
+// runs alongside the shell, so it gets a process of its own: the shell runs
+// itself again on the text of the command, with -c. The child shell starts
+// with what it would not find in its environment: the variables that are
+// not exported, the functions, the options and $?. The parent passes them
+// in stateVariable as a script, which LoadState runs, and $$ and $! in
+// pidsVariable.
+const stateVariable = "SIMPLE_SH_STATE"
+const pidsVariable = "SIMPLE_SH_PIDS"
+
+// childShell returns a command running script in a copy of the shell, with
+// args as $1, $2...
+func childShell(script string, args []string) (*exec.Cmd, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("cannot start a subshell: %w", err)
+	}
+
+	cmd := exec.Command(self, append([]string{"-c", script, util.GetVariable("0")}, args...)...)
+	cmd.Env = append(os.Environ(),
+		stateVariable+"="+shellState(),
+		pidsVariable+"="+util.GetVariable("$")+" "+util.GetVariable("!"))
+	return cmd, nil
+}
+
+// shellState returns the script setting up a child shell, see childShell
+func shellState() string {
+	var state strings.Builder
+
+	for _, name := range util.ShellVariableNames() {
+		fmt.Fprintf(&state, "%s=%s\n", name, quote(util.GetVariable(name)))
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(Functions)) {
+		state.WriteString(Functions[name].Source + "\n")
+	}
+
+	for _, name := range util.OptionNames() {
+		if util.Option(name) {
+			fmt.Fprintf(&state, "shopt -s %s\n", name)
+		}
+	}
+	for _, name := range util.SetOptionNames() {
+		if util.Option(name) {
+			fmt.Fprintf(&state, "set -o %s\n", name)
+		}
+	}
+
+	fmt.Fprintf(&state, "(exit %d)\n", util.ExitStatus())
+	return state.String()
+}
+
+// quote quotes a value so the shell reads it back as it is
+func quote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// LoadState sets up a child shell started by childShell. It does nothing in
+// a shell started otherwise.
+func LoadState() {
+	script, ok := os.LookupEnv(stateVariable)
+	if !ok {
+		return
+	}
+	os.Unsetenv(stateVariable)
+
+	var shellPid, lastBackgroundPid int
+	fmt.Sscan(os.Getenv(pidsVariable), &shellPid, &lastBackgroundPid)
+	os.Unsetenv(pidsVariable)
+	util.SetShellPid(shellPid)
+	util.SetLastBackgroundPid(lastBackgroundPid)
+
+	list, err := parser.Parse(script)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "cannot set up the subshell:", err)
+		return
+	}
+	RunList(list)
+}
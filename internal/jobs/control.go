@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+var jobControl bool // set once the shell owns an interactive terminal
+var shellPgid int
+var ttyFd int
+
+// InitJobControl puts the shell in a process group of its own in the
+// foreground of the terminal. Every pipeline started afterwards gets its own
+// process group, so Ctrl-C and Ctrl-Z reach the foreground job only.
+func InitJobControl(tty *os.File) {
+	ttyFd = int(tty.Fd())
+
+	// An interactive shell must not be stopped by Ctrl-Z itself. The signal
+	// is caught rather than ignored: an ignored signal stays ignored in the
+	// children, and they must stop on Ctrl-Z.
+	signal.Notify(make(chan os.Signal, 1), syscall.SIGTSTP)
+
+	// fails harmlessly when the shell already leads its session
+	syscall.Setpgid(0, 0)
+	shellPgid = syscall.Getpgrp()
+
+	giveTerminalTo(shellPgid)
+	jobControl = true
+}
+
+// giveTerminalTo makes pgid the foreground process group of the terminal.
+// SIGTTOU is ignored meanwhile since the shell itself may be in the
+// background when it takes the terminal back from a job.
+func giveTerminalTo(pgid int) {
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+
+	unix.IoctlSetPointerInt(ttyFd, unix.TIOCSPGRP, pgid)
+}
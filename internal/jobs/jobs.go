@@ -1,143 +1,460 @@
 package jobs // this handles background process management
 import (
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strings"
 	"syscall"
 )
 
 type Job struct {
-	ID int
-	PID int
+	ID            int
+	PID           int   // PID of the last process in the pipeline
+	PIDs          []int // every process of the pipeline, in order
+	Pgid          int   // process group shared by every process of the job, 0 without job control
 	CommandString string
-	Status string 
+	Status        string // "running", "stopped" or "done"
+
+	// Once the job is done: the exit status of its last process, or the
+	// signal that killed it
+	ExitCode int
+	Signal   syscall.Signal
+
+	exited  map[int]syscall.WaitStatus // processes that have terminated
+	notify  bool                       // the state changed and the user has not been told yet
+	touched int                        // when the job was last started, stopped or continued
+}
+
+var jobsMap = []*Job{}
+var clock int // incremented by touch
+
+var lastBackground int // PID of the job started last in the background
+
+// touch marks the job as the most recently used one, which makes it the
+// current job for fg, bg and %+
+func (job *Job) touch() {
+	clock++
+	job.touched = clock
 }
 
-var jobsMap = []Job{}
-var nextJobID = 1
+// ExecutePipeline starts every process of a pipeline. The caller has already
+// connected them with pipes and applied their redirections; parentFiles are
+// the shell's copies of those descriptors, closed once the children own them
+// so a reader sees EOF when its writer exits. A foreground pipeline is waited
+// for as a whole, a background one is registered as a single job.
+//
+// With job control every pipeline gets a process group of its own, and a
+// foreground one owns the terminal until it exits or is stopped with Ctrl-Z,
+// in which case it goes to the job table as "stopped".
+//
+// The returned status is the exit status of the last process, or 0 for a
+// background pipeline. An error is only returned when the shell itself could
+// not start the pipeline, together with the status to report for it.
+// Messages about the job, like its number once it runs in the background,
+// go to w.
+func ExecutePipeline(cmds []*exec.Cmd, parentFiles []*os.File, background bool, commandString string, w io.Writer) (int, error) {
+	closeAll := func() {
+		for _, f := range parentFiles {
+			f.Close()
+		}
+	}
+
+	if len(cmds) == 0 {
+		closeAll()
+		return 2, fmt.Errorf("no command provided")
+	}
+
+	job := &Job{CommandString: commandString, Status: "running", exited: map[int]syscall.WaitStatus{}}
+
+	// The reaper must not see a child exit before it is known to belong to
+	// the job, so the lock is held until every process is registered
+	startReaper()
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, cmd := range cmds {
+		if jobControl {
+			// the first process creates the group, the others join it
+			cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Pgid: job.Pgid}
+			if !background {
+				cmd.SysProcAttr.Foreground = true
+				cmd.SysProcAttr.Ctty = ttyFd
+			}
+		}
+
+		if err := cmd.Start(); err != nil {
+			closeAll()
+			// don't leave the processes already started behind
+			if len(job.PIDs) > 0 {
+				job.PID = job.PIDs[len(job.PIDs)-1]
+				for _, pid := range job.PIDs {
+					syscall.Kill(pid, syscall.SIGTERM)
+				}
+				waitForChange(job)
+			}
+			if jobControl && !background && job.Pgid != 0 {
+				giveTerminalTo(shellPgid)
+			}
+			return 126, fmt.Errorf("failed to start command: %w", err)
+		}
 
-func ExecuteCommandWithJobs(args []string) error{
-	if len(args) == 0 {
-		return fmt.Errorf("input is empty")
+		pid := cmd.Process.Pid
+		job.PIDs = append(job.PIDs, pid)
+		processes[pid] = job
+		// the reaper waits for it, the descriptor Go keeps on the process
+		// (a pidfd on Linux) would only leak
+		cmd.Process.Release()
+		if jobControl && job.Pgid == 0 {
+			job.Pgid = pid
+		}
 	}
+	job.PID = job.PIDs[len(job.PIDs)-1]
 
-	lastArgument := args[len(args)-1]
+	closeAll()
 
-	isBackground := false
+	if background {
+		addJob(job)
+		lastBackground = job.PID
 
-	if lastArgument == "&" { // check if the last argument is &
-		isBackground = true // set a flag that it is a background job
-		args = args[:len(args)-1] // ONLY remove & if it exists
+		// Print job notification
+		fmt.Fprintf(w, "[%d] %d\n", job.ID, job.PID)
+		return 0, nil
 	}
 
-	// Check again after removing &
-	if len(args) == 0 {
-		return fmt.Errorf("no command provided")
+	return waitForeground(job, w), nil
+}
+
+// LastBackgroundPID returns the PID of the last process of the job started
+// last in the background, what $! expands to, or 0 when there is none
+func LastBackgroundPID() int {
+	return lastBackground
+}
+
+// addJob gives the job the next free number and puts it in the table
+func addJob(job *Job) {
+	job.ID = 1
+	for _, j := range jobsMap {
+		if j.ID >= job.ID {
+			job.ID = j.ID + 1
+		}
 	}
+	jobsMap = append(jobsMap, job)
+	job.touch()
+}
 
-	command := args[0] // Extract the command name from the first element
+// waitForeground waits until every process of the job has exited or the job
+// has been stopped, then takes the terminal back. A stopped job is kept in
+// the job table, which is reported to w. Must be called with mu held.
+func waitForeground(job *Job, w io.Writer) int {
+	waitForChange(job)
+	job.notify = false // reported right here
 
-	path, err := exec.LookPath(command) // Searches your system's PATH for the executable
+	if jobControl {
+		giveTerminalTo(shellPgid)
+	}
 
-	if err != nil { // If the command wasn't found, return the error to the caller
-		return fmt.Errorf("command not found: %s", command)
+	if job.Status == "stopped" {
+		if !isInTable(job) {
+			addJob(job)
+		}
+		fmt.Fprintf(w, "\n[%d]+  Stopped    %s\n", job.ID, job.CommandString)
+		return 128 + int(syscall.SIGTSTP)
 	}
 
-	cmd := exec.Command(path, args[1:]...)
+	removeJob(job)
 
-	// Connect stdin, stdout, stderr
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	cmd.Stdin = os.Stdin
+	// the terminal echoed ^C, start the prompt on a line of its own
+	if jobControl && job.Signal == syscall.SIGINT {
+		fmt.Fprintln(w)
+	}
+	return job.exitStatus()
+}
 
-	if isBackground {
-		err := cmd.Start() // start and check error
+// exitStatus returns the shell exit status of a finished job. A job killed
+// by a signal reports 128 + the signal number.
+func (job *Job) exitStatus() int {
+	if job.Signal != 0 {
+		return 128 + int(job.Signal)
+	}
+	return job.ExitCode
+}
 
-		if err != nil {
-			return fmt.Errorf("failed to start command: %w", err)
+// describe returns the state of the job the way jobs and the notifications
+// show it: Running, Stopped, Done, Exit 1, Killed...
+func (job *Job) describe() string {
+	switch {
+	case job.Status == "running":
+		return "Running"
+	case job.Status == "stopped":
+		return "Stopped"
+	case job.Signal != 0:
+		name := job.Signal.String() // "killed", "terminated"...
+		return strings.ToUpper(name[:1]) + name[1:]
+	case job.ExitCode != 0:
+		return fmt.Sprintf("Exit %d", job.ExitCode)
+	}
+	return "Done"
+}
+
+// listing returns the command of the job the way the jobs listing shows
+// it, with a trailing & while it runs in the background
+func (job *Job) listing() string {
+	if job.Status == "running" {
+		return job.CommandString + " &"
+	}
+	return job.CommandString
+}
+
+// Foreground continues a job in the foreground, as the fg builtin does, and
+// returns its status once it exits or stops again. The command of the job is
+// echoed to stdout, errors and a new stop go to stderr.
+func Foreground(job *Job, stdout, stderr io.Writer) int {
+	fmt.Fprintln(stdout, job.CommandString)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if jobControl {
+		giveTerminalTo(job.Pgid)
+	}
+
+	if job.Status == "stopped" {
+		job.Status = "running"
+	}
+	job.touch()
+	if err := job.signal(syscall.SIGCONT); err != nil {
+		fmt.Fprintf(stderr, "fg: %v\n", err)
+	}
+
+	return waitForeground(job, stderr)
+}
+
+// Background continues a stopped job in the background, as the bg builtin
+// does, and writes its number and command to w.
+func Background(job *Job, w io.Writer) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	switch job.Status {
+	case "running":
+		return fmt.Errorf("job %d already in background", job.ID)
+	case "done":
+		return fmt.Errorf("job %d has terminated", job.ID)
+	}
+
+	if err := job.signal(syscall.SIGCONT); err != nil {
+		return err
+	}
+	job.Status = "running"
+	job.touch()
+
+	fmt.Fprintf(w, "[%d]+ %s &\n", job.ID, job.CommandString)
+	return nil
+}
+
+// signal sends sig to every process of the job: to its process group with
+// job control, else to each process still running
+func (job *Job) signal(sig syscall.Signal) error {
+	if job.Pgid != 0 {
+		return syscall.Kill(-job.Pgid, sig)
+	}
+
+	var err error
+	for _, pid := range job.PIDs {
+		if _, done := job.exited[pid]; !done {
+			if e := syscall.Kill(pid, sig); e != nil {
+				err = e
+			}
 		}
-		PID := cmd.Process.Pid
-
-		// Create job with nextJobID, not hardcoded 1
-		job := Job{
-			ID:            nextJobID,
-			PID:           PID,
-			CommandString: strings.Join(args, " "), // Join args as string, not cmd object
-			Status:        "running",
+	}
+	return err
+}
+
+// Kill sends a signal to a job, as the kill builtin does. A stopped job is
+// continued as well so it gets to handle a signal such as SIGTERM.
+func Kill(job *Job, sig syscall.Signal) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if job.Status == "done" {
+		return fmt.Errorf("%%%d: job has terminated", job.ID)
+	}
+
+	if err := job.signal(sig); err != nil {
+		return err
+	}
+	if job.Status == "stopped" && sig != syscall.SIGSTOP && sig != syscall.SIGTSTP && sig != 0 {
+		job.signal(syscall.SIGCONT)
+	}
+	return nil
+}
+
+// Wait waits for a job to finish, as the wait builtin does, and returns its
+// exit status. The job leaves the table without a notification. Ctrl-C stops
+// the waiting and returns 130.
+func Wait(job *Job) int {
+	interrupted := onInterrupt()
+	defer interrupted.stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for job.Status != "done" && !interrupted.seen {
+		changed.Wait()
+	}
+	if interrupted.seen {
+		return 128 + int(syscall.SIGINT)
+	}
+
+	job.notify = false
+	removeJob(job)
+	return job.exitStatus()
+}
+
+// WaitAll waits for every job in the table to finish, as wait without
+// arguments does.
+func WaitAll() int {
+	mu.Lock()
+	pending := append([]*Job(nil), jobsMap...)
+	mu.Unlock()
+
+	for _, job := range pending {
+		if status := Wait(job); status == 128+int(syscall.SIGINT) {
+			return status
 		}
+	}
+	return 0
+}
 
-		jobsMap = append(jobsMap, job) // Add job to the map
-		
-		// Print job notification
-		fmt.Printf("[%d] %d\n", job.ID, job.PID)
-		
-		nextJobID++ // Increment for next job
-	} else {
-		err = cmd.Run()
-		if err != nil {
-			return fmt.Errorf("command failed: %w", err)
+// WaitPid waits for the process pid, which must belong to one of the jobs,
+// and returns its exit status.
+func WaitPid(pid int) (int, error) {
+	mu.Lock()
+	var owner *Job
+	for _, job := range jobsMap {
+		for _, p := range job.PIDs {
+			if p == pid {
+				owner = job
+			}
 		}
 	}
+	mu.Unlock()
 
-	return nil
+	if owner == nil {
+		return 127, fmt.Errorf("pid %d is not a child of this shell", pid)
+	}
+
+	status := Wait(owner)
+	if pid == owner.PID || status == 128+int(syscall.SIGINT) {
+		return status, nil
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	ws := owner.exited[pid]
+	if ws.Signaled() {
+		return 128 + int(ws.Signal()), nil
+	}
+	return ws.ExitStatus(), nil
+}
+
+// interruption records a Ctrl-C received while waiting
+type interruption struct {
+	ch   chan os.Signal
+	seen bool
+}
+
+// onInterrupt wakes up the waiters of changed when SIGINT is received
+func onInterrupt() *interruption {
+	in := &interruption{ch: make(chan os.Signal, 1)}
+	signal.Notify(in.ch, syscall.SIGINT)
+
+	go func() {
+		if _, ok := <-in.ch; ok {
+			mu.Lock()
+			in.seen = true
+			changed.Broadcast()
+			mu.Unlock()
+		}
+	}()
+	return in
+}
+
+func (in *interruption) stop() {
+	signal.Stop(in.ch)
+	close(in.ch)
 }
 
-// Builtin function to list all jobs
-func ListJobs() {
+// Disown removes a job from the table: the shell stops tracking and
+// reporting it, but leaves its processes running.
+func Disown(job *Job) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	removeJob(job)
+}
+
+// Builtin function to list all jobs on w. Finished jobs are listed one last
+// time and dropped, their notification is not needed any more.
+func ListJobs(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+
 	if len(jobsMap) == 0 {
-		fmt.Println("No background jobs")
+		fmt.Fprintln(w, "No background jobs")
 		return
 	}
 
 	for _, job := range jobsMap {
-		fmt.Printf("[%d]  %s    %s\n", job.ID, job.Status, job.CommandString)
-	}
-}
-
-// Add these to your jobs package
-
-func UpdateJobStatus(jobID int, status string) {
-    for i := range jobsMap {
-        if jobsMap[i].ID == jobID {
-            jobsMap[i].Status = status
-            return
-        }
-    }
-}
-
-func RemoveCompletedJobs() {
-    var activeJobs []Job
-    
-    for _, job := range jobsMap {
-        // Check if process still exists
-        process, err := os.FindProcess(job.PID)
-        if err != nil {
-            // Process not found, skip it
-            continue
-        }
-        
-        // Try to signal the process (signal 0 doesn't actually send a signal, just checks if alive)
-        err = process.Signal(syscall.Signal(0))
-        if err != nil {
-            // Process finished, try to wait on it to clean up zombie
-            process.Wait()
-            fmt.Printf("[%d]  Done    %s\n", job.ID, job.CommandString)
-            continue
-        }
-        
-        // Process still running, keep it
-        activeJobs = append(activeJobs, job)
-    }
-    
-    jobsMap = activeJobs
-}
-
-func GetJobByID(id int) (*Job, error) {
-    for i := range jobsMap {
-        if jobsMap[i].ID == id {
-            return &jobsMap[i], nil
-        }
-    }
-    return nil, fmt.Errorf("job [%d] not found", id)
-}
\ No newline at end of file
+		fmt.Fprintf(w, "[%d]%c  %s    %s\n", job.ID, marker(job), job.describe(), job.listing())
+		job.notify = false
+	}
+	removeDoneJobs()
+}
+
+// NotifyJobChanges tells the user about the background jobs that finished or
+// stopped since the last prompt, eg. "[1]  Done    sleep 5", writing them to
+// w, and drops the finished ones. The shell calls it before showing each
+// prompt.
+func NotifyJobChanges(w io.Writer) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, job := range jobsMap {
+		if job.notify {
+			fmt.Fprintf(w, "[%d]%c  %s    %s\n", job.ID, marker(job), job.describe(), job.listing())
+			job.notify = false
+		}
+	}
+	removeDoneJobs()
+}
+
+func removeDoneJobs() {
+	var activeJobs []*Job
+	for _, job := range jobsMap {
+		if job.Status != "done" {
+			activeJobs = append(activeJobs, job)
+		}
+	}
+	jobsMap = activeJobs
+}
+
+func isInTable(job *Job) bool {
+	for _, j := range jobsMap {
+		if j == job {
+			return true
+		}
+	}
+	return false
+}
+
+func removeJob(job *Job) {
+	for i, j := range jobsMap {
+		if j == job {
+			jobsMap = append(jobsMap[:i], jobsMap[i+1:]...)
+			return
+		}
+	}
+}
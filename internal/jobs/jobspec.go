@@ -0,0 +1,98 @@
+package jobs
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// rankedJobs returns the jobs stopped ones first, then the most recently
+// started or continued: the first one is the current job (%+ or %%), the
+// second the previous job (%-). Must be called with mu held.
+func rankedJobs() []*Job {
+	ranked := append([]*Job(nil), jobsMap...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if (a.Status == "stopped") != (b.Status == "stopped") {
+			return a.Status == "stopped"
+		}
+		return a.touched > b.touched
+	})
+	return ranked
+}
+
+// marker returns the '+' of the current job and the '-' of the previous one
+// shown by jobs. Must be called with mu held.
+func marker(job *Job) byte {
+	ranked := rankedJobs()
+	switch {
+	case len(ranked) > 0 && ranked[0] == job:
+		return '+'
+	case len(ranked) > 1 && ranked[1] == job:
+		return '-'
+	}
+	return ' '
+}
+
+// FindJob returns the job named by a jobspec:
+//
+//	%n, n        job number n
+//	%%, %+, %    the current job, also used when spec is empty
+//	%-           the previous job
+//	%string      the job whose command starts with string
+//	%?string     the job whose command contains string
+func FindJob(spec string) (*Job, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	ranked := rankedJobs()
+	name := strings.TrimPrefix(spec, "%")
+
+	switch name {
+	case "", "%", "+":
+		if len(ranked) == 0 {
+			return nil, fmt.Errorf("no current job")
+		}
+		return ranked[0], nil
+	case "-":
+		if len(ranked) < 2 {
+			return nil, fmt.Errorf("%s: no such job", spec)
+		}
+		return ranked[1], nil
+	}
+
+	if id, err := strconv.Atoi(name); err == nil {
+		for _, job := range jobsMap {
+			if job.ID == id {
+				return job, nil
+			}
+		}
+		return nil, fmt.Errorf("%s: no such job", spec)
+	}
+
+	if !strings.HasPrefix(spec, "%") {
+		return nil, fmt.Errorf("%s: no such job", spec)
+	}
+
+	match := strings.HasPrefix
+	if strings.HasPrefix(name, "?") {
+		name = name[1:]
+		match = strings.Contains
+	}
+
+	var found *Job
+	for _, job := range jobsMap {
+		if !match(job.CommandString, name) {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%s: ambiguous job spec", spec)
+		}
+		found = job
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%s: no such job", spec)
+	}
+	return found, nil
+}
@@ -0,0 +1,100 @@
+package jobs
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// Children are reaped by a single goroutine woken up by SIGCHLD. Everything
+// it updates (the processes map and the state of the jobs) is guarded by mu,
+// and changed is broadcast whenever a job changes state so the shell can wait
+// for a foreground job without calling wait4 itself.
+var (
+	mu        sync.Mutex
+	changed   = sync.NewCond(&mu)
+	processes = map[int]*Job{} // job of every child not reaped yet, by pid
+
+	reaperOnce sync.Once
+)
+
+// startReaper installs the SIGCHLD handler the first time a child is started
+func startReaper() {
+	reaperOnce.Do(func() {
+		sigchld := make(chan os.Signal, 1)
+		signal.Notify(sigchld, syscall.SIGCHLD)
+
+		go func() {
+			for range sigchld {
+				reap()
+			}
+		}()
+	})
+}
+
+// reap collects every child that exited, stopped or continued. Signals do
+// not queue, one SIGCHLD may stand for several children, so it loops until
+// wait4 has nothing left to report.
+func reap() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	for {
+		var ws syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &ws, syscall.WNOHANG|syscall.WUNTRACED|syscall.WCONTINUED, nil)
+		if err == syscall.EINTR {
+			continue
+		}
+		if err != nil || pid <= 0 {
+			break
+		}
+
+		if job := processes[pid]; job != nil {
+			job.record(pid, ws)
+		}
+	}
+
+	changed.Broadcast()
+}
+
+// record updates the job with the new state of one of its processes. Once
+// the last one has terminated the job is done and keeps the exit status or
+// the signal of the last process of the pipeline. Must be called with mu held.
+func (job *Job) record(pid int, ws syscall.WaitStatus) {
+	switch {
+	case ws.Stopped():
+		if job.Status != "stopped" {
+			job.Status = "stopped"
+			job.notify = true
+		}
+		return
+	case ws.Continued():
+		job.Status = "running"
+		return
+	}
+
+	job.exited[pid] = ws
+	delete(processes, pid)
+
+	if len(job.exited) < len(job.PIDs) {
+		return
+	}
+
+	last := job.exited[job.PID]
+	if last.Signaled() {
+		job.Signal = last.Signal()
+	} else {
+		job.ExitCode = last.ExitStatus()
+	}
+	job.Status = "done"
+	job.notify = true
+}
+
+// waitForChange blocks until the job is no longer running: it is done or one
+// of its processes has stopped. Must be called with mu held.
+func waitForChange(job *Job) {
+	for job.Status == "running" {
+		changed.Wait()
+	}
+}
@@ -0,0 +1,117 @@
+package lineedit
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Candidate is one possible completion of the word under the cursor.
+type Candidate struct {
+	Text    string // replaces the word being completed
+	Display string // shown in the listing when there are several candidates
+}
+
+// Completer returns the candidates for the text left of the cursor and the
+// byte offset in line where the word they replace starts.
+type Completer func(line string) (start int, candidates []Candidate)
+
+// complete handles the Tab key. A single candidate replaces the word, several
+// ones insert their common prefix, and when that brings nothing new they are
+// listed in columns under the prompt.
+func (e *Editor) complete() {
+	if e.Complete == nil {
+		return
+	}
+
+	line := string(e.buf[:e.pos])
+	start, candidates := e.Complete(line)
+	if len(candidates) == 0 {
+		e.write("\a") // nothing to complete: ring the bell
+		return
+	}
+
+	word := line[start:]
+	wordStart := utf8.RuneCountInString(line[:start])
+
+	if len(candidates) == 1 {
+		text := candidates[0].Text
+		// a finished word gets a space, a directory keeps the cursor inside it
+		if !strings.HasSuffix(text, "/") {
+			text += " "
+		}
+		e.replace(wordStart, text)
+		return
+	}
+
+	prefix := candidates[0].Text
+	for _, c := range candidates[1:] {
+		prefix = commonPrefix(prefix, c.Text)
+	}
+
+	if len(prefix) > len(word) {
+		e.replace(wordStart, prefix)
+		return
+	}
+
+	e.showCandidates(candidates)
+}
+
+// replace swaps buf[start:pos] for text and puts the cursor after it
+func (e *Editor) replace(start int, text string) {
+	rest := e.buf[e.pos:]
+	buf := append([]rune(nil), e.buf[:start]...)
+	buf = append(buf, []rune(text)...)
+	e.pos = len(buf)
+	e.buf = append(buf, rest...)
+	e.refresh()
+}
+
+// showCandidates lists the candidates in columns, sorted down each column
+// like ls does, then draws the prompt again below them
+func (e *Editor) showCandidates(candidates []Candidate) {
+	names := make([]string, len(candidates))
+	width := 0
+	for i, c := range candidates {
+		names[i] = c.Display
+		if names[i] == "" {
+			names[i] = c.Text
+		}
+		width = max(width, utf8.RuneCountInString(names[i]))
+	}
+	sort.Strings(names)
+
+	width += 2 // space between columns
+	columns := max(1, terminalWidth(int(e.out.Fd()))/width)
+	rows := (len(names) + columns - 1) / columns
+
+	var sb strings.Builder
+	sb.WriteString("\r\n")
+	for row := 0; row < rows; row++ {
+		for col := 0; col < columns; col++ {
+			i := col*rows + row
+			if i >= len(names) {
+				break
+			}
+			sb.WriteString(names[i])
+			if col < columns-1 && i+rows < len(names) {
+				sb.WriteString(strings.Repeat(" ", width-utf8.RuneCountInString(names[i])))
+			}
+		}
+		sb.WriteString("\r\n")
+	}
+	e.write(sb.String())
+	e.refresh()
+}
+
+func commonPrefix(a, b string) string {
+	i := 0
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	// never cut a multi-byte character in half
+	for i > 0 && i < len(a) && !utf8.RuneStart(a[i]) {
+		i--
+	}
+	return a[:i]
+}
@@ -0,0 +1,404 @@
+// Package lineedit reads interactive input with an emacs-style line editor:
+// the terminal is put in raw mode while a line is typed so the cursor keys,
+// kill/yank and history recall can be handled by the shell itself.
+package lineedit
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ErrInterrupted is returned by ReadLine when the user presses Ctrl-C
+var ErrInterrupted = errors.New("interrupted")
+
+// Control keys
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyLineFeed  = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyCtrlY     = 25
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+type Editor struct {
+	in      *os.File
+	out     *os.File
+	history func() []string // previous commands, oldest first
+
+	// Complete is called when Tab is pressed, nil disables completion
+	Complete Completer
+
+	prompt string
+	buf    []rune // the line being edited
+	pos    int    // cursor position in buf
+	killed []rune // text removed by the last kill command, brought back by yank
+
+	hist      []string // snapshot of the history for this line
+	histIndex int      // entry shown, len(hist) means the line being typed
+	draft     []rune   // the line being typed while browsing the history
+}
+
+// New creates an editor reading keys from in and drawing on out.
+func New(in, out *os.File, history func() []string) *Editor {
+	return &Editor{in: in, out: out, history: history}
+}
+
+// ReadLine shows the prompt and returns the line typed by the user, without
+// the trailing newline. It returns io.EOF when Ctrl-D is pressed on an empty
+// line and ErrInterrupted when Ctrl-C is pressed.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	fd := int(e.in.Fd())
+	state, err := enableRawMode(fd)
+	if err != nil {
+		return "", err
+	}
+	defer restoreMode(fd, state)
+
+	e.prompt = prompt
+	e.buf = nil
+	e.pos = 0
+	e.hist = e.history()
+	e.histIndex = len(e.hist)
+	e.draft = nil
+	e.refresh()
+
+	for {
+		r, err := e.readRune()
+		if err != nil {
+			return "", err
+		}
+
+		// Ctrl-R searches the history, the key that ends the search is then
+		// handled on the line it leaves behind
+		if r == keyCtrlR {
+			r, err = e.reverseSearch()
+			if err != nil {
+				return "", err
+			}
+		}
+
+		switch r {
+		case 0: // consumed by the search
+		case keyEnter, keyLineFeed:
+			e.moveTo(len(e.buf))
+			e.write("\r\n")
+			return string(e.buf), nil
+
+		case keyCtrlC:
+			e.moveTo(len(e.buf))
+			e.write("^C\r\n")
+			return "", ErrInterrupted
+
+		case keyCtrlD:
+			if len(e.buf) == 0 {
+				e.write("\r\n")
+				return "", io.EOF
+			}
+			e.deleteRange(e.pos, e.pos+1)
+
+		case keyBackspace, keyCtrlH:
+			e.deleteRange(e.pos-1, e.pos)
+
+		case keyCtrlA:
+			e.moveTo(0)
+		case keyCtrlE:
+			e.moveTo(len(e.buf))
+		case keyCtrlB:
+			e.moveTo(e.pos - 1)
+		case keyCtrlF:
+			e.moveTo(e.pos + 1)
+
+		case keyCtrlK:
+			e.kill(e.pos, len(e.buf))
+		case keyCtrlU:
+			e.kill(0, e.pos)
+		case keyCtrlW:
+			e.kill(e.wordStart(unicode.IsSpace), e.pos)
+		case keyCtrlY:
+			e.insert(e.killed)
+
+		case keyCtrlP:
+			e.historyMove(-1)
+		case keyCtrlN:
+			e.historyMove(1)
+
+		case keyTab:
+			e.complete()
+
+		case keyCtrlL:
+			e.write("\x1b[H\x1b[2J")
+			e.refresh()
+
+		case keyEscape:
+			if err := e.handleEscape(); err != nil {
+				return "", err
+			}
+
+		default:
+			if unicode.IsPrint(r) {
+				e.insert([]rune{r})
+			}
+		}
+	}
+}
+
+// handleEscape reads the rest of an escape sequence: the cursor keys send
+// ESC [ x or ESC O x, Alt+key sends ESC key
+func (e *Editor) handleEscape() error {
+	r, err := e.readRune()
+	if err != nil {
+		return err
+	}
+
+	switch r {
+	case '[':
+		params, final, err := e.readCSI()
+		if err != nil {
+			return err
+		}
+		// Ctrl or Alt with an arrow key reports a modifier: ESC [ 1 ; 5 C
+		modified := strings.Contains(params, ";")
+
+		switch {
+		case final == 'A':
+			e.historyMove(-1)
+		case final == 'B':
+			e.historyMove(1)
+		case final == 'C' && modified:
+			e.moveTo(e.wordEnd())
+		case final == 'D' && modified:
+			e.moveTo(e.wordStart(isWordSeparator))
+		case final == 'C':
+			e.moveTo(e.pos + 1)
+		case final == 'D':
+			e.moveTo(e.pos - 1)
+		case final == 'H', final == '~' && (params == "1" || params == "7"):
+			e.moveTo(0)
+		case final == 'F', final == '~' && (params == "4" || params == "8"):
+			e.moveTo(len(e.buf))
+		case final == '~' && params == "3":
+			e.deleteRange(e.pos, e.pos+1)
+		}
+
+	case 'O':
+		r, err := e.readRune()
+		if err != nil {
+			return err
+		}
+		switch r {
+		case 'A':
+			e.historyMove(-1)
+		case 'B':
+			e.historyMove(1)
+		case 'C':
+			e.moveTo(e.pos + 1)
+		case 'D':
+			e.moveTo(e.pos - 1)
+		case 'H':
+			e.moveTo(0)
+		case 'F':
+			e.moveTo(len(e.buf))
+		}
+
+	case 'b', 'B':
+		e.moveTo(e.wordStart(isWordSeparator))
+	case 'f', 'F':
+		e.moveTo(e.wordEnd())
+	case 'd', 'D':
+		e.kill(e.pos, e.wordEnd())
+	case keyBackspace, keyCtrlH:
+		e.kill(e.wordStart(isWordSeparator), e.pos)
+	}
+
+	return nil
+}
+
+// readCSI reads the parameters and the final byte of ESC [ ... sequence
+func (e *Editor) readCSI() (string, rune, error) {
+	var params strings.Builder
+	for {
+		r, err := e.readRune()
+		if err != nil {
+			return "", 0, err
+		}
+		if r >= 0x40 && r <= 0x7e {
+			return params.String(), r, nil
+		}
+		params.WriteRune(r)
+	}
+}
+
+// readRune reads one UTF-8 encoded key. Reads are unbuffered so nothing typed
+// ahead is kept from the commands the shell runs next.
+func (e *Editor) readRune() (rune, error) {
+	var b [utf8.UTFMax]byte
+
+	if _, err := io.ReadFull(e.in, b[:1]); err != nil {
+		return 0, err
+	}
+	if b[0] < utf8.RuneSelf {
+		return rune(b[0]), nil
+	}
+
+	n := 1
+	for n < utf8.UTFMax && !utf8.FullRune(b[:n]) {
+		if _, err := io.ReadFull(e.in, b[n:n+1]); err != nil {
+			return 0, err
+		}
+		n++
+	}
+
+	r, _ := utf8.DecodeRune(b[:n])
+	return r, nil
+}
+
+func (e *Editor) write(s string) {
+	e.out.WriteString(s)
+}
+
+func (e *Editor) insert(text []rune) {
+	if len(text) == 0 {
+		return
+	}
+	buf := make([]rune, 0, len(e.buf)+len(text))
+	buf = append(buf, e.buf[:e.pos]...)
+	buf = append(buf, text...)
+	buf = append(buf, e.buf[e.pos:]...)
+	e.buf = buf
+	e.pos += len(text)
+	e.refresh()
+}
+
+// deleteRange removes buf[from:to] and leaves the cursor at from
+func (e *Editor) deleteRange(from, to int) {
+	from = max(from, 0)
+	to = min(to, len(e.buf))
+	if from >= to {
+		return
+	}
+	e.buf = append(e.buf[:from], e.buf[to:]...)
+	e.pos = from
+	e.refresh()
+}
+
+// kill removes buf[from:to] and keeps it for yank
+func (e *Editor) kill(from, to int) {
+	from = max(from, 0)
+	to = min(to, len(e.buf))
+	if from >= to {
+		return
+	}
+	e.killed = append([]rune(nil), e.buf[from:to]...)
+	e.deleteRange(from, to)
+}
+
+func (e *Editor) moveTo(pos int) {
+	pos = max(0, min(pos, len(e.buf)))
+	if pos != e.pos {
+		e.pos = pos
+		e.refresh()
+	}
+}
+
+func isWordSeparator(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+}
+
+// wordStart returns the start of the word before the cursor
+func (e *Editor) wordStart(isSeparator func(rune) bool) int {
+	i := e.pos
+	for i > 0 && isSeparator(e.buf[i-1]) {
+		i--
+	}
+	for i > 0 && !isSeparator(e.buf[i-1]) {
+		i--
+	}
+	return i
+}
+
+// wordEnd returns the end of the word after the cursor
+func (e *Editor) wordEnd() int {
+	i := e.pos
+	for i < len(e.buf) && isWordSeparator(e.buf[i]) {
+		i++
+	}
+	for i < len(e.buf) && !isWordSeparator(e.buf[i]) {
+		i++
+	}
+	return i
+}
+
+// historyMove shows an older (-1) or newer (+1) history entry. The line being
+// typed is kept aside and comes back after the newest entry.
+func (e *Editor) historyMove(delta int) {
+	index := e.histIndex + delta
+	if index < 0 || index > len(e.hist) {
+		return
+	}
+
+	if e.histIndex == len(e.hist) {
+		e.draft = append([]rune(nil), e.buf...)
+	}
+	e.histIndex = index
+
+	if index == len(e.hist) {
+		e.buf = e.draft
+	} else {
+		e.buf = []rune(e.hist[index])
+	}
+	e.pos = len(e.buf)
+	e.refresh()
+}
+
+// refresh redraws the prompt and the line
+func (e *Editor) refresh() {
+	e.draw(e.prompt, e.buf, e.pos)
+}
+
+// draw shows prompt and buf with the cursor at pos. A line wider than the
+// terminal scrolls horizontally so the cursor always stays visible.
+func (e *Editor) draw(prompt string, buf []rune, pos int) {
+	promptWidth := utf8.RuneCountInString(prompt)
+	available := terminalWidth(int(e.out.Fd())) - promptWidth - 1
+	if available < 1 {
+		available = 1
+	}
+
+	start := 0
+	if pos > available {
+		start = pos - available
+	}
+	end := min(len(buf), start+available)
+
+	var sb strings.Builder
+	sb.WriteString("\r")
+	sb.WriteString(prompt)
+	sb.WriteString(string(buf[start:end]))
+	sb.WriteString("\x1b[K") // clear whatever is left of the previous line
+	sb.WriteString("\r")
+	if column := promptWidth + pos - start; column > 0 {
+		fmt.Fprintf(&sb, "\x1b[%dC", column)
+	}
+	e.write(sb.String())
+}
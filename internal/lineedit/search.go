@@ -0,0 +1,101 @@
+package lineedit
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// reverseSearch runs the incremental history search started by Ctrl-R.
+// Typing narrows the search, Ctrl-R again finds the next older match and
+// Backspace widens it again. Ctrl-G cancels and brings back the line as it
+// was. Any other key accepts the match into the line and is returned so the
+// caller handles it: Enter runs the line, a cursor key starts editing it.
+// 0 is returned when the key was consumed.
+func (e *Editor) reverseSearch() (rune, error) {
+	savedBuf := append([]rune(nil), e.buf...)
+	savedPos := e.pos
+
+	var query []rune
+	index := len(e.hist) // history entry of the current match
+	matchPos := 0        // rune offset of the query in that entry
+	failed := false
+
+	// search looks for the query in entries older than from, newest first,
+	// passing over the ones equal to skip
+	search := func(from int, skip string) {
+		q := string(query)
+		for i := from - 1; i >= 0; i-- {
+			if e.hist[i] == skip {
+				continue
+			}
+			if at := strings.LastIndex(e.hist[i], q); at >= 0 {
+				index = i
+				matchPos = len([]rune(e.hist[i][:at]))
+				failed = false
+				return
+			}
+		}
+		failed = true
+	}
+
+	for {
+		line := []rune(nil)
+		if index < len(e.hist) {
+			line = []rune(e.hist[index])
+		}
+
+		label := "reverse-i-search"
+		if failed {
+			label = "failed reverse-i-search"
+		}
+		e.draw(fmt.Sprintf("(%s)`%s': ", label, string(query)), line, matchPos)
+
+		r, err := e.readRune()
+		if err != nil {
+			return 0, err
+		}
+
+		switch {
+		case r == keyCtrlR:
+			// a repeated entry would show the same match twice
+			if len(query) > 0 && index < len(e.hist) {
+				search(index, e.hist[index])
+			}
+
+		case r == keyBackspace || r == keyCtrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				index = len(e.hist)
+				matchPos = 0
+				if len(query) > 0 {
+					search(len(e.hist), "")
+				} else {
+					failed = false
+				}
+			}
+
+		case r == keyCtrlG:
+			e.buf, e.pos = savedBuf, savedPos
+			e.refresh()
+			return 0, nil
+
+		case r == keyCtrlC:
+			e.buf, e.pos = savedBuf, savedPos
+			return r, nil
+
+		case unicode.IsPrint(r):
+			query = append(query, r)
+			// the current match may still contain the longer query
+			search(min(index+1, len(e.hist)), "")
+
+		default:
+			if index < len(e.hist) {
+				e.buf, e.pos = line, matchPos
+				e.histIndex = index
+			}
+			e.refresh()
+			return r, nil
+		}
+	}
+}
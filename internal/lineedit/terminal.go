@@ -0,0 +1,49 @@
+package lineedit
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// IsTerminal reports whether f is connected to a terminal
+func IsTerminal(f *os.File) bool {
+	_, err := unix.IoctlGetTermios(int(f.Fd()), ioctlGetTermios)
+	return err == nil
+}
+
+// enableRawMode switches the terminal to raw mode so every key press is
+// delivered immediately and nothing is echoed. It returns the previous state
+// for restoreMode.
+func enableRawMode(fd int) (*unix.Termios, error) {
+	old, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := *old
+	raw.Iflag &^= unix.BRKINT | unix.ICRNL | unix.INPCK | unix.ISTRIP | unix.IXON
+	raw.Cflag |= unix.CS8
+	// ISIG is off as well: Ctrl-C and Ctrl-Z arrive as plain bytes while editing
+	raw.Lflag &^= unix.ECHO | unix.ICANON | unix.IEXTEN | unix.ISIG
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+
+	if err := unix.IoctlSetTermios(fd, ioctlSetTermios, &raw); err != nil {
+		return nil, err
+	}
+	return old, nil
+}
+
+func restoreMode(fd int, state *unix.Termios) {
+	unix.IoctlSetTermios(fd, ioctlSetTermios, state)
+}
+
+// terminalWidth returns the number of columns of the terminal, 80 if unknown
+func terminalWidth(fd int) int {
+	ws, err := unix.IoctlGetWinsize(fd, unix.TIOCGWINSZ)
+	if err != nil || ws.Col == 0 {
+		return 80
+	}
+	return int(ws.Col)
+}
@@ -0,0 +1,404 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// The syntax tree produced by Parse. Every node remembers where it started in
+// the source so errors can point at it.
+//
+// eg: cd src && make > build.log 2> errors.log; (ls | wc -l) &
+//
+// List
+// ├── ListItem
+// │   └── AndOr  [&&]
+// │       ├── Pipeline: SimpleCommand  cd src
+// │       └── Pipeline: SimpleCommand  make  > build.log  2> errors.log
+// └── ListItem  Background
+//     └── AndOr
+//         └── Pipeline: Subshell
+//             └── List
+//                 └── ... Pipeline: SimpleCommand ls | SimpleCommand wc -l
+
+// Pos is a position in the source text. Line and Col start at 1.
+type Pos struct {
+	Offset int
+	Line   int
+	Col    int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%d:%d", p.Line, p.Col)
+}
+
+// Node is implemented by every element of the syntax tree.
+type Node interface {
+	Pos() Pos
+}
+
+// List is a sequence of and-or lists separated by ;, & or newlines.
+type List struct {
+	Position Pos
+	Items    []*ListItem
+}
+
+// ListItem is one entry of a List. Background is set when it ended with &.
+type ListItem struct {
+	Position   Pos
+	AndOr      *AndOr
+	Background bool
+	Source     string // text of the item, shown by the jobs builtin
+}
+
+// AndOr is a chain of pipelines joined by && and ||.
+// Operators[i] sits between Pipelines[i] and Pipelines[i+1].
+type AndOr struct {
+	Position  Pos
+	Pipelines []*Pipeline
+	Operators []string
+}
+
+// Pipeline is one or more commands connected with |
+type Pipeline struct {
+	Position Pos
+	Commands []Command
+	Source   string // text of the pipeline, shown by the jobs builtin
+
+	// Sources holds the text of each command, without the redirections
+	// after a compound command, to run it again in another shell
+	Sources []string
+}
+
+// Command is a single element of a pipeline: a SimpleCommand or one of the
+// compound commands.
+type Command interface {
+	Node
+	commandNode()
+}
+
+// SimpleCommand is a command name with its arguments, optionally preceded by
+// variable assignments, with its redirections in source order.
+// eg: FOO=1 make -j4 > out.log 2> err.log
+type SimpleCommand struct {
+	Position Pos
+	Assigns  []*Assign
+	Args     []*Word
+	Redirs   []*Redirect
+}
+
+// Subshell is a list run in a child environment: ( list )
+type Subshell struct {
+	Position Pos
+	Body     *List
+	Redirs   []*Redirect
+}
+
+// Group is a list run in the current environment: { list; }
+type Group struct {
+	Position Pos
+	Body     *List
+	Redirs   []*Redirect
+}
+
+// IfClause runs the body of the first branch whose condition succeeds, or
+// Else when none does.
+// eg: if a; then b; elif c; then d; else e; fi has two Branches
+type IfClause struct {
+	Position Pos
+	Branches []*CondBranch
+	Else     *List // nil without an else part
+	Redirs   []*Redirect
+}
+
+// CondBranch is an if or elif condition with the body it guards
+type CondBranch struct {
+	Cond *List
+	Body *List
+}
+
+// WhileClause runs Body as long as Cond succeeds, or with Until set as long
+// as it fails.
+type WhileClause struct {
+	Position Pos
+	Until    bool
+	Cond     *List
+	Body     *List
+	Redirs   []*Redirect
+}
+
+// ForClause runs Body once for each word with the variable Name set to it.
+// Without "in" (for x; do ...) it loops over the positional parameters.
+type ForClause struct {
+	Position Pos
+	Name     string
+	In       bool
+	Words    []*Word
+	Body     *List
+	Redirs   []*Redirect
+}
+
+// ArithForClause is the C-style loop for ((Init; Cond; Post)). The three
+// arithmetic expressions are words read like ArithExp, any of them may be
+// empty.
+type ArithForClause struct {
+	Position Pos
+	Init     *Word // no parts when left out
+	Cond     *Word
+	Post     *Word
+	Body     *List
+	Redirs   []*Redirect
+}
+
+// ArithCommand evaluates an arithmetic expression, ((expr)). Its status is
+// 0 when the value is not 0, like a condition in C.
+type ArithCommand struct {
+	Position Pos
+	Expr     *Word
+	Redirs   []*Redirect
+}
+
+// CaseClause runs the body of the first item with a pattern matching Word.
+type CaseClause struct {
+	Position Pos
+	Word     *Word
+	Items    []*CaseItem
+	Redirs   []*Redirect
+}
+
+// CaseItem is one pat1|pat2) list ;; arm of a case. Terminator is ";;",
+// ";&" to go on with the next body without testing its patterns, or ";;&"
+// to go on testing the patterns of the next items.
+type CaseItem struct {
+	Position   Pos
+	Patterns   []*Word
+	Body       *List
+	Terminator string
+}
+
+// FunctionDef defines a function, name() compound or function name compound.
+// Calling it runs Body, with the redirections written after it.
+type FunctionDef struct {
+	Position Pos
+	Name     string
+	Body     Command
+	Source   string // text of the whole definition
+}
+
+// Redirects returns the redirections of a command
+func Redirects(cmd Command) []*Redirect {
+	switch cmd := cmd.(type) {
+	case *SimpleCommand:
+		return cmd.Redirs
+	case *Subshell:
+		return cmd.Redirs
+	case *Group:
+		return cmd.Redirs
+	case *IfClause:
+		return cmd.Redirs
+	case *WhileClause:
+		return cmd.Redirs
+	case *ForClause:
+		return cmd.Redirs
+	case *ArithForClause:
+		return cmd.Redirs
+	case *CaseClause:
+		return cmd.Redirs
+	case *ArithCommand:
+		return cmd.Redirs
+	}
+	return nil
+}
+
+// Assign is a NAME=value word in front of a simple command.
+type Assign struct {
+	Position Pos
+	Name     string
+	Value    *Word
+}
+
+// Redirect sends file descriptor Fd to or from Target. Op is one of
+//
+//	<  >  >>  >|  <>   open the file Target
+//	<&  >&             make Fd a copy of descriptor Target, or close it when
+//	                   Target is -: 2>&1, 3<&-
+//	&>  &>>            send both 1 and 2 to Target, Fd is 1
+//	<<  <<-            here-document: Fd reads Body, the lines following the
+//	                   command up to the delimiter Target
+//	<<<                here-string: Fd reads Target and a newline
+//
+// Redirections apply in source order, so cmd > log 2>&1 sends both outputs
+// to log while cmd 2>&1 > log sends only the standard output there.
+type Redirect struct {
+	Position Pos
+	Fd       int
+	Op       string
+	Target   *Word
+	Body     *Word // of a here-document, quoted unless expanded
+}
+
+// Word is a shell word made of literal and quoted parts, command
+// substitutions, arithmetic and parameter expansions in braces.
+// eg: abc"def $x"'ghi' is [Lit abc] [DblQuoted [Lit "def $x"]] [SglQuoted ghi]
+type Word struct {
+	Position Pos
+	Parts    []WordPart
+}
+
+// WordPart is one piece of a Word.
+type WordPart interface {
+	wordPart()
+}
+
+// Lit is unquoted text as written in the source, backslashes included.
+type Lit struct {
+	Value string
+}
+
+// SglQuoted is the text between single quotes.
+type SglQuoted struct {
+	Value string
+}
+
+// DblQuoted is the content of a double-quoted string.
+type DblQuoted struct {
+	Parts []WordPart
+}
+
+// CmdSubst is a command substitution, $(list) or `list`, replaced by the
+// output of the list when the word is expanded. Source is its text as
+// written.
+type CmdSubst struct {
+	Position Pos
+	Body     *List
+	Source   string
+}
+
+// ArithExp is an arithmetic expansion, $((expr)), replaced by the value of
+// the expression. Expr holds the expression as if it were between double
+// quotes, its variables and command substitutions are expanded first.
+type ArithExp struct {
+	Position Pos
+	Expr     *Word
+	Source   string
+}
+
+// ParamExp is a parameter expansion in braces: ${name}, ${#name} for its
+// length, or ${name op word} where op is one of
+//
+//	:- - := = :? ? :+ +   default, assigned default, error, alternate value
+//	# ## % %%             remove the shortest or longest matching prefix or suffix
+//	/ // /# /%            replace the first, every, leading or trailing match: Arg/Arg2
+//	^^ ^ ,, ,             convert to upper or lower case, all or the first letter
+//	:                     substring, ${name:Arg:Arg2}
+//
+// The colon of :- := :? :+ makes an empty value count as unset.
+type ParamExp struct {
+	Position Pos
+	Name     string
+	Length   bool
+	Op       string
+	Arg      *Word
+	Arg2     *Word
+	Source   string
+}
+
+func (l *List) Pos() Pos           { return l.Position }
+func (l *ListItem) Pos() Pos       { return l.Position }
+func (a *AndOr) Pos() Pos          { return a.Position }
+func (p *Pipeline) Pos() Pos       { return p.Position }
+func (c *SimpleCommand) Pos() Pos  { return c.Position }
+func (s *Subshell) Pos() Pos       { return s.Position }
+func (g *Group) Pos() Pos          { return g.Position }
+func (c *IfClause) Pos() Pos       { return c.Position }
+func (c *WhileClause) Pos() Pos    { return c.Position }
+func (c *ForClause) Pos() Pos      { return c.Position }
+func (c *ArithForClause) Pos() Pos { return c.Position }
+func (c *ArithCommand) Pos() Pos   { return c.Position }
+func (c *CaseClause) Pos() Pos     { return c.Position }
+func (c *CaseItem) Pos() Pos       { return c.Position }
+func (f *FunctionDef) Pos() Pos    { return f.Position }
+func (a *Assign) Pos() Pos         { return a.Position }
+func (r *Redirect) Pos() Pos       { return r.Position }
+func (w *Word) Pos() Pos           { return w.Position }
+func (c *CmdSubst) Pos() Pos       { return c.Position }
+func (a *ArithExp) Pos() Pos       { return a.Position }
+func (p *ParamExp) Pos() Pos       { return p.Position }
+
+func (*SimpleCommand) commandNode()  {}
+func (*Subshell) commandNode()       {}
+func (*Group) commandNode()          {}
+func (*IfClause) commandNode()       {}
+func (*WhileClause) commandNode()    {}
+func (*ForClause) commandNode()      {}
+func (*ArithForClause) commandNode() {}
+func (*ArithCommand) commandNode()   {}
+func (*CaseClause) commandNode()     {}
+func (*FunctionDef) commandNode()    {}
+
+func (*Lit) wordPart()       {}
+func (*SglQuoted) wordPart() {}
+func (*DblQuoted) wordPart() {}
+func (*CmdSubst) wordPart()  {}
+func (*ArithExp) wordPart()  {}
+func (*ParamExp) wordPart()  {}
+
+// Value returns the word with quotes removed and backslash escapes resolved.
+func (w *Word) Value() string {
+	var sb strings.Builder
+	writeParts(&sb, w.Parts, false)
+	return sb.String()
+}
+
+func writeParts(sb *strings.Builder, parts []WordPart, inDoubleQuotes bool) {
+	for _, part := range parts {
+		switch p := part.(type) {
+		case *Lit:
+			sb.WriteString(Unescape(p.Value, inDoubleQuotes))
+		case *SglQuoted:
+			sb.WriteString(p.Value)
+		case *DblQuoted:
+			writeParts(sb, p.Parts, true)
+		case *CmdSubst:
+			sb.WriteString(p.Source)
+		case *ArithExp:
+			sb.WriteString(p.Source)
+		case *ParamExp:
+			sb.WriteString(p.Source)
+		}
+	}
+}
+
+// Unescape removes the backslashes that quote the following character.
+// Inside double quotes a backslash only quotes $ ` " \ and newline.
+func Unescape(s string, inDoubleQuotes bool) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			next := s[i+1]
+			if !inDoubleQuotes || strings.IndexByte("$`\"\\\n", next) >= 0 {
+				i++
+				if next != '\n' { // backslash-newline is a line continuation
+					sb.WriteByte(next)
+				}
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// IsLiteral reports whether the word is plain unquoted text equal to s.
+// Reserved words such as { and } are only recognized in this form.
+func (w *Word) IsLiteral(s string) bool {
+	if len(w.Parts) != 1 {
+		return false
+	}
+	lit, ok := w.Parts[0].(*Lit)
+	return ok && lit.Value == s
+}
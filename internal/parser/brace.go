@@ -0,0 +1,268 @@
+package parser
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Brace expansion turns one word into several, before any other expansion:
+//
+//	a{b,c}d          abd acd
+//	{a,b}{1,2}       a1 a2 b1 b2
+//	x{a,b{c,d}}      xa xbc xbd
+//	{1..5}           1 2 3 4 5
+//	{01..10..3}      01 04 07 10
+//	{a..e}           a b c d e
+//
+// Only unquoted braces count: "{a,b}" and \{a,b} stay as they are. A brace
+// without a comma or a valid sequence inside is an ordinary character, like
+// in {} or {a}.
+
+// braceItem is a character of an unquoted part of a word, or when part is
+// set a piece that brace expansion leaves alone: a quoted string, an escaped
+// character or an expansion
+type braceItem struct {
+	ch   byte
+	part WordPart
+}
+
+func (it braceItem) is(ch byte) bool {
+	return it.part == nil && it.ch == ch
+}
+
+// maxBraceWords limits how many words a brace expansion makes, so that a
+// typo like {1..1000000000} does not exhaust the memory
+const maxBraceWords = 1 << 20
+
+// ExpandBraces returns the words a word expands to, or the word itself when
+// it has nothing to expand.
+func ExpandBraces(word *Word) []*Word {
+	items := braceItems(word.Parts)
+	if !hasBraces(items) {
+		return []*Word{word}
+	}
+
+	var words []*Word
+	for _, expanded := range expandBraces(items) {
+		words = append(words, &Word{Position: word.Position, Parts: braceParts(expanded)})
+	}
+	return words
+}
+
+func hasBraces(items []braceItem) bool {
+	for _, it := range items {
+		if it.is('{') {
+			return true
+		}
+	}
+	return false
+}
+
+// braceItems splits the unquoted literal parts of a word into characters
+func braceItems(parts []WordPart) []braceItem {
+	var items []braceItem
+	for _, part := range parts {
+		lit, ok := part.(*Lit)
+		if !ok {
+			items = append(items, braceItem{part: part})
+			continue
+		}
+		for i := 0; i < len(lit.Value); i++ {
+			if lit.Value[i] == '\\' && i+1 < len(lit.Value) {
+				items = append(items, braceItem{part: &Lit{Value: lit.Value[i : i+2]}})
+				i++
+				continue
+			}
+			items = append(items, braceItem{ch: lit.Value[i]})
+		}
+	}
+	return items
+}
+
+// braceParts puts characters back together into word parts
+func braceParts(items []braceItem) []WordPart {
+	var parts []WordPart
+	var lit strings.Builder
+
+	for _, it := range items {
+		if it.part == nil {
+			lit.WriteByte(it.ch)
+			continue
+		}
+		if escaped, ok := it.part.(*Lit); ok {
+			lit.WriteString(escaped.Value)
+			continue
+		}
+		if lit.Len() > 0 {
+			parts = append(parts, &Lit{Value: lit.String()})
+			lit.Reset()
+		}
+		parts = append(parts, it.part)
+	}
+
+	if lit.Len() > 0 {
+		parts = append(parts, &Lit{Value: lit.String()})
+	}
+	return parts
+}
+
+// expandBraces expands the first brace expression of items, then the
+// following ones in each of the results
+func expandBraces(items []braceItem) [][]braceItem {
+	for open := range items {
+		if !items[open].is('{') {
+			continue
+		}
+
+		alternatives, end := braceAlternatives(items, open)
+		if end < 0 {
+			continue
+		}
+
+		prefix, suffix := items[:open], items[end+1:]
+		var results [][]braceItem
+		for _, alternative := range alternatives {
+			word := make([]braceItem, 0, len(prefix)+len(alternative)+len(suffix))
+			word = append(word, prefix...)
+			word = append(word, alternative...)
+			word = append(word, suffix...)
+			results = append(results, expandBraces(word)...)
+			if len(results) > maxBraceWords {
+				break
+			}
+		}
+		return results
+	}
+
+	return [][]braceItem{items}
+}
+
+// braceAlternatives reads the brace expression opening at items[open]. It
+// returns what each of its words puts in place of the expression, and the
+// index of the closing brace, -1 when there is no valid expression there.
+func braceAlternatives(items []braceItem, open int) ([][]braceItem, int) {
+	depth := 0
+	start := open + 1
+	var alternatives [][]braceItem
+
+	for i := open + 1; i < len(items); i++ {
+		switch {
+		case items[i].is('{'):
+			depth++
+		case items[i].is('}') && depth > 0:
+			depth--
+		case items[i].is('}'):
+			if alternatives == nil {
+				// no comma: maybe a sequence like {1..10}
+				sequence := braceSequence(items[open+1 : i])
+				if sequence == nil {
+					return nil, -1
+				}
+				for _, s := range sequence {
+					alternatives = append(alternatives, literalItems(s))
+				}
+				return alternatives, i
+			}
+			return append(alternatives, items[start:i]), i
+		case items[i].is(',') && depth == 0:
+			alternatives = append(alternatives, items[start:i])
+			start = i + 1
+		}
+	}
+	return nil, -1
+}
+
+func literalItems(s string) []braceItem {
+	items := make([]braceItem, len(s))
+	for i := range s {
+		items[i] = braceItem{ch: s[i]}
+	}
+	return items
+}
+
+// braceSequence returns the words of a sequence expression such as 1..10,
+// 0..100..5 or a..z, nil when items is not one
+func braceSequence(items []braceItem) []string {
+	var sb strings.Builder
+	for _, it := range items {
+		if it.part != nil {
+			return nil
+		}
+		sb.WriteByte(it.ch)
+	}
+
+	fields := strings.Split(sb.String(), "..")
+	if len(fields) != 2 && len(fields) != 3 {
+		return nil
+	}
+
+	step := uint64(1)
+	if len(fields) == 3 {
+		n, err := strconv.ParseInt(fields[2], 10, 64)
+		if err != nil {
+			return nil
+		}
+		if n < 0 {
+			n = -n // still negative for the smallest int64, right once unsigned
+		}
+		if n != 0 {
+			step = uint64(n)
+		}
+	}
+
+	// a..z: single characters
+	if len(fields[0]) == 1 && len(fields[1]) == 1 && !isDigitByte(fields[0][0]) && !isDigitByte(fields[1][0]) {
+		from, to := int64(fields[0][0]), int64(fields[1][0])
+		return sequence(from, to, step, func(n int64) string { return string(rune(n)) })
+	}
+
+	from, err1 := strconv.ParseInt(fields[0], 10, 64)
+	to, err2 := strconv.ParseInt(fields[1], 10, 64)
+	if err1 != nil || err2 != nil {
+		return nil
+	}
+
+	// {01..10}: a leading zero pads every number to the widest end
+	width := 0
+	if hasLeadingZero(fields[0]) || hasLeadingZero(fields[1]) {
+		width = max(len(fields[0]), len(fields[1]))
+	}
+	return sequence(from, to, step, func(n int64) string {
+		s := strconv.FormatInt(n, 10)
+		if n < 0 {
+			return "-" + strings.Repeat("0", max(0, width-len(s))) + s[1:]
+		}
+		return strings.Repeat("0", max(0, width-len(s))) + s
+	})
+}
+
+// sequence counts from from to to by step, up or down. The number of words
+// is worked out first, with unsigned numbers, so that counting does not
+// overflow near the ends of int64: {9223372036854775806..9223372036854775807}
+func sequence(from, to int64, step uint64, format func(int64) string) []string {
+	distance := uint64(to) - uint64(from)
+	if from > to {
+		distance = uint64(from) - uint64(to)
+	}
+	count := min(distance/step+1, maxBraceWords)
+
+	words := make([]string, 0, count)
+	for i := uint64(0); i < count; i++ {
+		offset := i * step // at most distance
+		if from > to {
+			words = append(words, format(int64(uint64(from)-offset)))
+		} else {
+			words = append(words, format(int64(uint64(from)+offset)))
+		}
+	}
+	return words
+}
+
+func hasLeadingZero(s string) bool {
+	s = strings.TrimPrefix(s, "-")
+	return len(s) > 1 && s[0] == '0'
+}
+
+func isDigitByte(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
@@ -0,0 +1,106 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		word string
+		want string // the words, separated by spaces
+	}{
+		// sequences
+		{"{1..5}", "1 2 3 4 5"},
+		{"{5..1}", "5 4 3 2 1"},
+		{"{-3..3..2}", "-3 -1 1 3"},
+		{"{1..3}x", "1x 2x 3x"},
+		{"v{0..2}", "v0 v1 v2"},
+		{"{9223372036854775806..9223372036854775807}", "9223372036854775806 9223372036854775807"},
+		{"{-9223372036854775807..-9223372036854775808}", "-9223372036854775807 -9223372036854775808"},
+
+		// steps, whose sign does not matter
+		{"{1..10..3}", "1 4 7 10"},
+		{"{10..1..3}", "10 7 4 1"},
+		{"{1..10..-3}", "1 4 7 10"},
+		{"{1..3..0}", "1 2 3"},
+
+		// zero padding to the widest end
+		{"{01..10..3}", "01 04 07 10"},
+		{"{8..010}", "008 009 010"},
+		{"{-05..5..5}", "-05 000 005"},
+
+		// characters
+		{"{a..e}", "a b c d e"},
+		{"{e..a..2}", "e c a"},
+		{"{a..z}", "a b c d e f g h i j k l m n o p q r s t u v w x y z"},
+		{"{a..a}", "a"},
+		{"{x..z}{1..2}", "x1 x2 y1 y2 z1 z2"},
+
+		// not sequences
+		{"{1..}", "{1..}"},
+		{"{a..10}", "{a..10}"},
+		{"{1..2..x}", "{1..2..x}"},
+		{"{ab..c}", "{ab..c}"},
+
+		// lists
+		{"a{b,c}d", "abd acd"},
+		{"{a,b}{1,2}", "a1 a2 b1 b2"},
+		{"x{a,b{c,d}}", "xa xbc xbd"},
+		{"a{,b}", "a ab"},
+		{"{a,b}{", "a{ b{"},
+		{"{1..3}{a,b}", "1a 1b 2a 2b 3a 3b"},
+
+		// nothing to expand
+		{"{}", "{}"},
+		{"{a}", "{a}"},
+		{`"{a,b}"`, `"{a,b}"`},
+		{`'{a,b}'`, `'{a,b}'`},
+		{`\{a,b}`, `\{a,b}`},
+		{`{a,"b,c"}`, `a "b,c"`},
+	}
+
+	for _, tt := range tests {
+		word, err := newLexer(tt.word).readWord()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.word, err)
+			continue
+		}
+
+		var got []string
+		for _, w := range ExpandBraces(word) {
+			got = append(got, wordString(w.Parts))
+		}
+		if strings.Join(got, " ") != tt.want {
+			t.Errorf("ExpandBraces(%s) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
+
+func TestExpandBracesLimit(t *testing.T) {
+	word, err := newLexer("{1..10000000}").readWord()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(ExpandBraces(word)); n != maxBraceWords {
+		t.Errorf("{1..10000000} made %d words, want %d", n, maxBraceWords)
+	}
+}
+
+// wordString writes the parts of a word back the way they were quoted
+func wordString(parts []WordPart) string {
+	var sb strings.Builder
+	for _, part := range parts {
+		switch part := part.(type) {
+		case *Lit:
+			sb.WriteString(part.Value)
+		case *SglQuoted:
+			sb.WriteString("'" + part.Value + "'")
+		case *DblQuoted:
+			sb.WriteString(`"` + wordString(part.Parts) + `"`)
+		default:
+			sb.WriteString("?")
+		}
+	}
+	return sb.String()
+}
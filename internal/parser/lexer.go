@@ -0,0 +1,696 @@
+package parser
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokWord
+	tokOperator
+	tokNewline
+)
+
+// token is a single word or operator read by the lexer.
+// Operators are only recognized outside quotes, so `echo ">"` is a word.
+type token struct {
+	kind  tokenKind
+	value string // operator text, or the source text of a word
+	word  *Word
+	fd    int // file descriptor of a redirection operator
+	pos   Pos
+	end   int // offset right after the token
+}
+
+// ParseError is a syntax error at a given position of the input.
+// Incomplete is set when the input ended too early, in the middle of a
+// quote or a compound command: more lines could make it valid.
+type ParseError struct {
+	Pos        Pos
+	Msg        string
+	Incomplete bool
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// lexer splits the input into tokens on demand, so the parser can decide how
+// to read what comes next.
+type lexer struct {
+	src    string
+	offset int
+	line   int
+	col    int
+
+	// here-documents whose body starts after the next newline
+	hereDocs []*hereDoc
+}
+
+// hereDoc is a here-document redirection waiting for its body
+type hereDoc struct {
+	redir     *Redirect
+	delimiter string
+	quoted    bool // the delimiter was quoted: the body is not expanded
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: src, line: 1, col: 1}
+}
+
+func (l *lexer) pos() Pos {
+	return Pos{Offset: l.offset, Line: l.line, Col: l.col}
+}
+
+func (l *lexer) peekByte(ahead int) byte {
+	if l.offset+ahead < len(l.src) {
+		return l.src[l.offset+ahead]
+	}
+	return 0
+}
+
+// advance moves past n bytes, keeping track of lines and columns
+func (l *lexer) advance(n int) {
+	for i := 0; i < n && l.offset < len(l.src); i++ {
+		if l.src[l.offset] == '\n' {
+			l.line++
+			l.col = 1
+		} else {
+			l.col++
+		}
+		l.offset++
+	}
+}
+
+func (l *lexer) errorf(pos Pos, format string, args ...interface{}) error {
+	return &ParseError{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// incomplete builds the error for input that ends too early
+func (l *lexer) incomplete(pos Pos, msg string) error {
+	return &ParseError{Pos: pos, Msg: msg, Incomplete: true}
+}
+
+// operators of more than one character, longest first. Any other operator
+// is a single character.
+var operators = []string{
+	";;&", // ends a case item and keeps testing the next ones
+	"<<<", "<<-", "&>>", "&&", "||", ";;", ";&",
+	">>", ">&", ">|", "<<", "<&", "<>", "&>",
+}
+
+// redirectFds maps each redirection operator to the descriptor it acts on
+// when no number comes before it. &> and &>> act on both 1 and 2.
+var redirectFds = map[string]int{
+	"<": 0, "<&": 0, "<>": 0, "<<": 0, "<<-": 0, "<<<": 0,
+	">": 1, ">>": 1, ">|": 1, ">&": 1,
+	"&>": 1, "&>>": 1,
+}
+
+// maxFd is the highest descriptor a redirection may name
+const maxFd = 255
+
+// isOperatorStart reports whether ch begins an operator outside quotes
+func isOperatorStart(ch byte) bool {
+	return strings.IndexByte("|&;<>()", ch) >= 0
+}
+
+// next reads the next token
+func (l *lexer) next() (token, error) {
+	// skip blanks, line continuations and comments
+	for l.offset < len(l.src) {
+		ch := l.src[l.offset]
+		if ch == ' ' || ch == '\t' || ch == '\r' {
+			l.advance(1)
+			continue
+		}
+		if ch == '\\' && l.peekByte(1) == '\n' {
+			l.advance(2)
+			continue
+		}
+		if ch == '#' {
+			for l.offset < len(l.src) && l.src[l.offset] != '\n' {
+				l.advance(1)
+			}
+			continue
+		}
+		break
+	}
+
+	start := l.pos()
+
+	if l.offset >= len(l.src) {
+		if len(l.hereDocs) > 0 {
+			return token{}, l.incomplete(start, fmt.Sprintf("here-document not ended (wanted `%s')", l.hereDocs[0].delimiter))
+		}
+		return token{kind: tokEOF, pos: start, end: l.offset}, nil
+	}
+
+	ch := l.src[l.offset]
+
+	if ch == '\n' {
+		l.advance(1)
+		// the bodies of the here-documents of the line follow it
+		for len(l.hereDocs) > 0 {
+			if err := l.readHereDoc(l.hereDocs[0]); err != nil {
+				return token{}, err
+			}
+			l.hereDocs = l.hereDocs[1:]
+		}
+		return token{kind: tokNewline, value: "\n", pos: start, end: l.offset}, nil
+	}
+
+	// digits right before < or > give the descriptor a redirection acts
+	// on, as in "cmd 2> err" or "exec 3< file"
+	ioNumber := -1
+	if isDigitByte(ch) {
+		end := l.offset
+		for end < len(l.src) && isDigitByte(l.src[end]) {
+			end++
+		}
+		if end < len(l.src) && (l.src[end] == '<' || l.src[end] == '>') {
+			n, err := strconv.Atoi(l.src[l.offset:end])
+			if err != nil || n > maxFd {
+				return token{}, l.errorf(start, "%s: bad file descriptor", l.src[l.offset:end])
+			}
+			ioNumber = n
+			l.advance(end - l.offset)
+			ch = l.src[l.offset]
+		}
+	}
+
+	if isOperatorStart(ch) {
+		op := string(ch)
+		for _, candidate := range operators {
+			if strings.HasPrefix(l.src[l.offset:], candidate) {
+				op = candidate
+				break
+			}
+		}
+		l.advance(len(op))
+
+		fd, isRedirect := redirectFds[op]
+		if !isRedirect {
+			fd = -1
+		} else if ioNumber >= 0 {
+			fd = ioNumber
+		}
+		return token{kind: tokOperator, value: op, fd: fd, pos: start, end: l.offset}, nil
+	}
+
+	word, err := l.readWord()
+	if err != nil {
+		return token{}, err
+	}
+	return token{kind: tokWord, value: l.src[start.Offset:l.offset], word: word, pos: start, end: l.offset}, nil
+}
+
+// readHereDoc reads the body of a here-document, the lines up to the one
+// holding just its delimiter. With <<- leading tabs are dropped from each of
+// them, so the body can be indented along with the script.
+func (l *lexer) readHereDoc(h *hereDoc) error {
+	start := l.pos()
+	var body strings.Builder
+
+	for {
+		if l.offset >= len(l.src) {
+			return l.incomplete(h.redir.Position, fmt.Sprintf("here-document not ended (wanted `%s')", h.delimiter))
+		}
+
+		line := l.src[l.offset:]
+		length := len(line)
+		if end := strings.IndexByte(line, '\n'); end >= 0 {
+			line = line[:end]
+			length = end + 1
+		}
+		if h.redir.Op == "<<-" {
+			line = strings.TrimLeft(line, "\t")
+		}
+		l.advance(length)
+
+		if line == h.delimiter {
+			break
+		}
+		body.WriteString(line)
+		body.WriteByte('\n')
+	}
+
+	word := &Word{Position: start}
+	if h.quoted {
+		word.Parts = []WordPart{&SglQuoted{Value: body.String()}}
+	} else {
+		// expanded like text between double quotes
+		sub := &lexer{src: body.String(), line: start.Line, col: start.Col}
+		parts, _, err := sub.readQuotedParts(0)
+		if err != nil {
+			return err
+		}
+		word.Parts = []WordPart{&DblQuoted{Parts: parts}}
+	}
+	h.redir.Body = word
+	return nil
+}
+
+// hereDocDelimiter returns the delimiter of a here-document given the source
+// of the word following << and whether any of it was quoted. Nothing is
+// expanded in it: <<$x ends at a line holding $x.
+func hereDocDelimiter(src string) (string, bool) {
+	var delimiter strings.Builder
+	quoted := false
+	var quote byte
+	for i := 0; i < len(src); i++ {
+		ch := src[i]
+		switch {
+		case ch == quote:
+			quote = 0
+		case quote == 0 && (ch == '\'' || ch == '"'):
+			quote = ch
+			quoted = true
+		case ch == '\\' && quote != '\'' && i+1 < len(src):
+			quoted = true
+			i++
+			delimiter.WriteByte(src[i])
+		default:
+			delimiter.WriteByte(ch)
+		}
+	}
+	return delimiter.String(), quoted
+}
+
+// readArithmetic reads an arithmetic expression in double parentheses,
+// ((expr)), starting over at start where the parser found "((". It returns
+// the expression, in which parentheses must balance.
+func (l *lexer) readArithmetic(start Pos) (string, error) {
+	l.offset, l.line, l.col = start.Offset, start.Line, start.Col
+	l.advance(2)
+
+	begin := l.offset
+	depth := 0
+	for l.offset < len(l.src) {
+		switch l.src[l.offset] {
+		case '(':
+			depth++
+		case ')':
+			if depth > 0 {
+				depth--
+				break
+			}
+			if l.peekByte(1) != ')' {
+				return "", l.errorf(l.pos(), "syntax error: missing `))'")
+			}
+			expr := l.src[begin:l.offset]
+			l.advance(2)
+			return expr, nil
+		}
+		l.advance(1)
+	}
+
+	return "", l.incomplete(start, "unclosed (( in input")
+}
+
+// readWord reads a word up to the next unquoted blank or operator
+func (l *lexer) readWord() (*Word, error) {
+	return l.readWordUntil(func(ch byte) bool {
+		return ch == ' ' || ch == '\t' || ch == '\r' || ch == '\n' || isOperatorStart(ch)
+	}, false)
+}
+
+// readWordUntil reads a word up to the first unquoted byte for which atEnd
+// is true. Within double quotes single quotes are kept: "${x:-'}'}" gives '}'.
+func (l *lexer) readWordUntil(atEnd func(ch byte) bool, inDoubleQuotes bool) (*Word, error) {
+	word := &Word{Position: l.pos()}
+	var lit strings.Builder
+
+	flushLit := func() {
+		if lit.Len() > 0 {
+			word.Parts = append(word.Parts, &Lit{Value: lit.String()})
+			lit.Reset()
+		}
+	}
+
+	for l.offset < len(l.src) {
+		ch := l.src[l.offset]
+
+		switch {
+		case atEnd(ch):
+			flushLit()
+			return word, nil
+
+		case ch == '\\':
+			// keep the backslash, quote removal drops it later
+			if l.offset+1 >= len(l.src) {
+				return nil, l.incomplete(l.pos(), "trailing backslash at end of input")
+			}
+			lit.WriteString(l.src[l.offset : l.offset+2])
+			l.advance(2)
+
+		case ch == '\'':
+			start := l.pos()
+			end := strings.IndexByte(l.src[l.offset+1:], '\'')
+			if end < 0 {
+				return nil, l.incomplete(start, "unclosed quote in input")
+			}
+			if inDoubleQuotes {
+				lit.WriteString(l.src[l.offset : l.offset+end+2])
+			} else {
+				flushLit()
+				word.Parts = append(word.Parts, &SglQuoted{Value: l.src[l.offset+1 : l.offset+1+end]})
+			}
+			l.advance(end + 2)
+
+		case ch == '"':
+			flushLit()
+			part, err := l.readDoubleQuoted()
+			if err != nil {
+				return nil, err
+			}
+			word.Parts = append(word.Parts, part)
+
+		case ch == '$' && l.peekByte(1) == '(' || ch == '`':
+			flushLit()
+			part, err := l.readSubstitution(false)
+			if err != nil {
+				return nil, err
+			}
+			word.Parts = append(word.Parts, part)
+
+		case ch == '$' && l.peekByte(1) == '{':
+			flushLit()
+			part, err := l.readParamExp(inDoubleQuotes)
+			if err != nil {
+				return nil, err
+			}
+			word.Parts = append(word.Parts, part)
+
+		default:
+			lit.WriteByte(ch)
+			l.advance(1)
+		}
+	}
+
+	flushLit()
+	return word, nil
+}
+
+// readDoubleQuoted reads "..." with the lexer on the opening quote
+func (l *lexer) readDoubleQuoted() (*DblQuoted, error) {
+	start := l.pos()
+	l.advance(1)
+
+	parts, closed, err := l.readQuotedParts('"')
+	if err != nil {
+		return nil, err
+	}
+	if !closed {
+		return nil, l.incomplete(start, "unclosed quote in input")
+	}
+	return &DblQuoted{Parts: parts}, nil
+}
+
+// readQuotedParts reads text the way it is read between double quotes, up to
+// the closing quote, or to the end of the input when quote is 0. It reports
+// whether the closing quote was found.
+func (l *lexer) readQuotedParts(quote byte) ([]WordPart, bool, error) {
+	var parts []WordPart
+	var lit strings.Builder
+
+	flushLit := func() {
+		if lit.Len() > 0 {
+			parts = append(parts, &Lit{Value: lit.String()})
+			lit.Reset()
+		}
+	}
+
+	for l.offset < len(l.src) {
+		ch := l.src[l.offset]
+		switch {
+		case ch == quote:
+			l.advance(1)
+			flushLit()
+			return parts, true, nil
+		case ch == '\\' && l.offset+1 < len(l.src):
+			// with no quote around, as in a here-document, \" keeps its
+			// backslash
+			if quote == 0 && l.peekByte(1) == '"' {
+				lit.WriteByte('\\')
+			}
+			lit.WriteString(l.src[l.offset : l.offset+2])
+			l.advance(2)
+			continue
+		case ch == '$' && l.peekByte(1) == '(' || ch == '`':
+			flushLit()
+			part, err := l.readSubstitution(true)
+			if err != nil {
+				return nil, false, err
+			}
+			parts = append(parts, part)
+			continue
+		case ch == '$' && l.peekByte(1) == '{':
+			flushLit()
+			part, err := l.readParamExp(true)
+			if err != nil {
+				return nil, false, err
+			}
+			parts = append(parts, part)
+			continue
+		}
+		lit.WriteByte(ch)
+		l.advance(1)
+	}
+
+	flushLit()
+	return parts, quote == 0, nil
+}
+
+// readSubstitution reads what starts with $( or a backquote: an arithmetic
+// expansion $((expr)) or a command substitution. Like in bash, $(( that
+// does not end with )) starts a command substitution with a subshell:
+// $((cd dir; ls) | wc -l)
+func (l *lexer) readSubstitution(inDoubleQuotes bool) (WordPart, error) {
+	if l.peekByte(0) == '$' && l.peekByte(2) == '(' {
+		start := l.pos()
+		inner := Pos{Offset: start.Offset + 1, Line: start.Line, Col: start.Col + 1}
+		expr, err := l.readArithmetic(inner)
+		if err == nil {
+			word, err := arithWord(expr, Pos{Offset: start.Offset + 3, Line: start.Line, Col: start.Col + 3})
+			if err != nil {
+				return nil, err
+			}
+			return &ArithExp{Position: start, Expr: word, Source: l.src[start.Offset:l.offset]}, nil
+		}
+		if perr, ok := err.(*ParseError); ok && perr.Incomplete {
+			return nil, err
+		}
+		l.offset, l.line, l.col = start.Offset, start.Line, start.Col
+	}
+
+	return l.readCmdSubst(inDoubleQuotes)
+}
+
+// arithWord turns the text of an arithmetic expression found at pos into a
+// word read as if between double quotes, so that $x and $(cmd) in it are
+// expanded before evaluation
+func arithWord(expr string, pos Pos) (*Word, error) {
+	l := &lexer{src: expr, line: pos.Line, col: pos.Col}
+	parts, _, err := l.readQuotedParts(0)
+	if err != nil {
+		return nil, err
+	}
+	return &Word{Position: pos, Parts: parts}, nil
+}
+
+// readCmdSubst reads a command substitution with the lexer on its $( or
+// opening backquote.
+//
+// The body of $(...) is parsed right away from the same input, so the
+// parser finds the closing parenthesis: it is not the one of a case pattern
+// or of a nested substitution, as in $(case $x in a) echo $(ls);; esac).
+func (l *lexer) readCmdSubst(inDoubleQuotes bool) (*CmdSubst, error) {
+	start := l.pos()
+	if l.src[l.offset] == '`' {
+		return l.readBackquoted(start, inDoubleQuotes)
+	}
+	l.advance(2)
+
+	p := &parser{src: l.src, lex: l}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	body, err := p.parseList(")")
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOperator(")") {
+		if p.tok.kind == tokEOF {
+			return nil, l.incomplete(start, "unclosed $( in input")
+		}
+		return nil, p.unexpected()
+	}
+
+	return &CmdSubst{Position: start, Body: body, Source: l.src[start.Offset:l.offset]}, nil
+}
+
+// readBackquoted reads the old form of command substitution, `...`. Inside
+// it a backslash quotes $, ` and \ (and " within double quotes), which is
+// how backquotes nest:
+//
+//	echo `echo \`date\``
+func (l *lexer) readBackquoted(start Pos, inDoubleQuotes bool) (*CmdSubst, error) {
+	l.advance(1)
+
+	var body strings.Builder
+	for l.offset < len(l.src) {
+		ch := l.src[l.offset]
+		switch {
+		case ch == '`':
+			l.advance(1)
+			list, err := Parse(body.String())
+			if perr, ok := err.(*ParseError); ok {
+				return nil, l.errorf(start, "in command substitution: %s", perr.Msg)
+			} else if err != nil {
+				return nil, err
+			}
+			return &CmdSubst{Position: start, Body: list, Source: l.src[start.Offset:l.offset]}, nil
+
+		case ch == '\\' && l.offset+1 < len(l.src):
+			next := l.src[l.offset+1]
+			if strings.IndexByte("$`\\", next) < 0 && !(inDoubleQuotes && next == '"') {
+				body.WriteByte(ch)
+			}
+			body.WriteByte(next)
+			l.advance(2)
+
+		default:
+			body.WriteByte(ch)
+			l.advance(1)
+		}
+	}
+
+	return nil, l.incomplete(start, "unclosed ` in input")
+}
+
+// paramOperators are the operators of ${name op word}, longest first
+var paramOperators = []string{
+	":-", ":=", ":?", ":+", "-", "=", "?", "+",
+	"##", "#", "%%", "%",
+	"//", "/#", "/%", "/",
+	"^^", "^", ",,", ",",
+	":",
+}
+
+// readParamExp reads a parameter expansion ${...} with the lexer on the $.
+// Blanks and operators don't end the words inside the braces, so
+// ${x:-a b} is one word.
+//
+// Within double quotes the single quotes in the word of ${x:-word} and the
+// like are kept, as in bash, but they still quote a pattern: "${x#'*'}".
+func (l *lexer) readParamExp(inDoubleQuotes bool) (*ParamExp, error) {
+	start := l.pos()
+	l.advance(2)
+	exp := &ParamExp{Position: start}
+
+	// ${#name} is the length of name, but ${#} alone is $#
+	if l.peekByte(0) == '#' && l.peekByte(1) != '}' {
+		exp.Length = true
+		l.advance(1)
+	}
+
+	exp.Name = l.readParamName()
+	if exp.Name == "" {
+		return nil, l.badSubstitution(start)
+	}
+
+	if !exp.Length && l.peekByte(0) != '}' {
+		for _, op := range paramOperators {
+			if strings.HasPrefix(l.src[l.offset:], op) {
+				exp.Op = op
+				break
+			}
+		}
+		if exp.Op == "" {
+			return nil, l.badSubstitution(start)
+		}
+		l.advance(len(exp.Op))
+
+		// ${name:offset:length} and ${name/pattern/replacement} have a
+		// second word
+		second := ""
+		switch exp.Op {
+		case ":":
+			second = ":"
+		case "/", "//", "/#", "/%":
+			second = "/"
+		}
+
+		literalQuotes := false
+		switch strings.TrimPrefix(exp.Op, ":") {
+		case "-", "=", "?", "+":
+			literalQuotes = inDoubleQuotes
+		}
+
+		var err error
+		if exp.Arg, err = l.readParamWord(second, literalQuotes); err != nil {
+			return nil, err
+		}
+		if second != "" && l.peekByte(0) == second[0] {
+			l.advance(1)
+			if exp.Arg2, err = l.readParamWord("", false); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if l.offset >= len(l.src) {
+		return nil, l.incomplete(start, "unclosed ${ in input")
+	}
+	if l.src[l.offset] != '}' {
+		return nil, l.badSubstitution(start)
+	}
+	l.advance(1)
+
+	exp.Source = l.src[start.Offset:l.offset]
+	return exp, nil
+}
+
+// readParamName reads the name in ${...}: a variable name, a positional
+// parameter with any number of digits, or a special parameter
+func (l *lexer) readParamName() string {
+	begin := l.offset
+	switch ch := l.peekByte(0); {
+	case ch >= '0' && ch <= '9':
+		for ch := l.peekByte(0); ch >= '0' && ch <= '9'; ch = l.peekByte(0) {
+			l.advance(1)
+		}
+	case strings.IndexByte("?#@*$!", ch) >= 0:
+		l.advance(1)
+	default:
+		for l.offset < len(l.src) && isNameByte(l.src[l.offset], l.offset == begin) {
+			l.advance(1)
+		}
+	}
+	return l.src[begin:l.offset]
+}
+
+// readParamWord reads the word after the operator of a parameter expansion,
+// up to the closing brace or to stop
+func (l *lexer) readParamWord(stop string, inDoubleQuotes bool) (*Word, error) {
+	return l.readWordUntil(func(ch byte) bool {
+		return ch == '}' || stop != "" && ch == stop[0]
+	}, inDoubleQuotes)
+}
+
+func (l *lexer) badSubstitution(start Pos) error {
+	end := strings.IndexByte(l.src[start.Offset:], '}')
+	if end < 0 {
+		return l.incomplete(start, "unclosed ${ in input")
+	}
+	return l.errorf(start, "%s: bad substitution", l.src[start.Offset:start.Offset+end+1])
+}
+
+func isNameByte(ch byte, first bool) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_' || !first && ch >= '0' && ch <= '9'
+}
@@ -1,185 +1,838 @@
 package parser
 
 import (
-	"fmt"
 	"strings"
 )
 
-type CommandDetails struct{
-	CommandString string
-	Args []string
-	InputFile string
-	OutputFile string 
-	Append bool
-	ErrorFile string
-	Background bool
-}
-
-// eg CommandString: ls -l >> out.txt &
-// Expected result: 
-// Args: ["ls", "-l"]
-// OutputFile: out.txt
-// Append: true
-// Background: true
-
-// eg 2 CommandString: cat < in.txt 2> err.txt
-// Args: ["cat"]
-// InputFile: in.txt
-// ErrorFile: err.txt
-
-func Parse(line string) (*CommandDetails, error){
-    // tokens = tokenize(line)
-    tokens, err := tokenize(line)
-    // if error → return error
-    if err != nil {
-        return nil, err
-    }
-
-    // create empty CommandDetails cmd
-    cmd := CommandDetails{}
-
-    
-
-    // i = 0
-    i := 0
-    // while i < length(tokens):
-    for i < len(tokens) {
-        // token = tokens[i]
-        token := tokens[i]
-
-        //     switch token:
-        switch token {
-        case "<":
-            if i+1 >= len(tokens) {
-                return nil, fmt.Errorf("missing input file")
-            }
-
-            cmd.InputFile = tokens[i+1]
-            i+=2
-            continue
-        case ">":
-            if i+1 >= len(tokens) {
-                return nil, fmt.Errorf("missing output file")
-            }
-            cmd.OutputFile = tokens[i+1]
-            cmd.Append=false
-            i+=2
-            continue
-        case ">>":
-            if i+1 >= len(tokens) {
-                return nil,fmt.Errorf("missing output file")
-            }
-            cmd.OutputFile = tokens[i+1]
-            cmd.Append = true
-            i+=2
-            continue
-        case "2>":
-            if i+1 >= len(tokens) {
-                return nil, fmt.Errorf("missing error file")
-            }
-            cmd.ErrorFile = tokens[i+1]
-            i+=2
-            continue
-        case "&":
-            cmd.Background = true
-            i++
-            continue
-        default:
-            cmd.Args = append(cmd.Args, token)
-            i++
-        }
-    }
-
-    if len(cmd.Args) == 0 {
-        return nil, fmt.Errorf("no command provided")
-    }
-
-	return &cmd, nil
-}
-
-func tokenize(input string) ([]string, error) { // this is to produce []string where each element is one argument/operator/filename
-	var tokens []string
-	var current strings.Builder // used to build strings by appending data without creating many temporary string objects
-	var inSingleQuotes, inDoubleQuotes bool
-	var backlash bool
-
-	inSingleQuotes = false
-	inDoubleQuotes = false 
-	backlash = false
-
-	var ch1 rune = '"'
-	var ch2 rune = '\''
-
-	for _, character := range input{
-		// if the previous character was a backlash, treat this character as normal data
-		if backlash {
-			current.WriteRune(character)
-			backlash = false
-			continue
-		} 
-		
-		// if the current character is a backlash, escape the next character
-		if character == '\\' {
-			backlash = true
-			continue
+// The grammar understood by Parse, in the spirit of the POSIX shell grammar:
+//
+//	list      := and_or ((';' | '&' | newline) and_or)* [';' | '&']
+//	and_or    := pipeline (('&&' | '||') newline* pipeline)*
+//	pipeline  := command ('|' newline* command)*
+//	command   := simple_command
+//	           | '(' list ')' redirect*
+//	           | '((' expr '))' redirect*
+//	           | '{' list '}' redirect*
+//	           | if_clause redirect*
+//	           | while_clause redirect*
+//	           | for_clause redirect*
+//	           | case_clause redirect*
+//	           | function_def
+//	if_clause := 'if' list 'then' list ('elif' list 'then' list)* ['else' list] 'fi'
+//	while_clause := ('while' | 'until') list do_group
+//	for_clause := 'for' name newline* ['in' word* (';' | newline)] newline* do_group
+//	            | 'for' '((' expr ';' expr ';' expr '))' [';'] newline* do_group
+//	do_group  := 'do' list 'done'
+//	case_clause := 'case' word newline* 'in' newline* case_item* 'esac'
+//	case_item := ['('] word ('|' word)* ')' list [';;' | ';&' | ';;&'] newline*
+//	simple_command := (assignment | redirect)* (word | redirect)*
+//	redirect  := [io_number] ('<' | '>' | '>>' | '>|' | '<>' | '<&' | '>&') word
+//	           | ('&>' | '&>>' | '<<<') word
+//	           | [io_number] ('<<' | '<<-') word    the body follows the line
+//
+// Reserved words such as if, then and fi are only recognized where a command
+// name may appear, so echo fi prints fi.
+
+type parser struct {
+	src     string
+	lex     *lexer
+	tok     token // current token
+	lastEnd int   // offset right after the last consumed token
+}
+
+// Parse turns a line of input into a syntax tree.
+//
+// eg: ls -l >> out.txt &
+// List
+// └── ListItem  Background
+//     └── AndOr
+//         └── Pipeline
+//             └── SimpleCommand  Args: [ls -l]  Redirs: [1 >> out.txt]
+func Parse(src string) (*List, error) {
+	p := &parser{src: src, lex: newLexer(src)}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	list, err := p.parseList()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind != tokEOF {
+		return nil, p.unexpected()
+	}
+
+	return list, nil
+}
+
+// next consumes the current token and reads the following one
+func (p *parser) next() error {
+	p.lastEnd = p.tok.end
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) isOperator(op string) bool {
+	return p.tok.kind == tokOperator && p.tok.value == op
+}
+
+// isReserved reports whether the current token is the reserved word s.
+// Reserved words are only special where a command name may appear.
+func (p *parser) isReserved(s string) bool {
+	return p.tok.kind == tokWord && p.tok.word.IsLiteral(s)
+}
+
+func (p *parser) isRedirect() bool {
+	return p.tok.kind == tokOperator && p.tok.fd >= 0
+}
+
+func (p *parser) skipNewlines() error {
+	for p.tok.kind == tokNewline {
+		if err := p.next(); err != nil {
+			return err
 		}
-		
-		// if we are in single quotes
-		if inSingleQuotes {
-			if character == ch2 { // closing single quote
-				inSingleQuotes = false 
-			} else {
-				current.WriteRune(character) // everything else is literal
+	}
+	return nil
+}
+
+// unexpected builds the error for the current token
+func (p *parser) unexpected() error {
+	switch p.tok.kind {
+	case tokEOF:
+		return &ParseError{Pos: p.tok.pos, Msg: "syntax error: unexpected end of input", Incomplete: true}
+	case tokNewline:
+		return &ParseError{Pos: p.tok.pos, Msg: "syntax error near unexpected token `newline'"}
+	}
+	return &ParseError{Pos: p.tok.pos, Msg: "syntax error near unexpected token `" + p.tok.value + "'"}
+}
+
+// atListEnd reports whether the current token closes the list being parsed:
+// the end of input, or one of closers: an operator such as ")" or ";;", or a
+// reserved word such as "fi"
+func (p *parser) atListEnd(closers []string) bool {
+	if p.tok.kind == tokEOF {
+		return true
+	}
+	for _, closer := range closers {
+		if isOperatorStart(closer[0]) && p.isOperator(closer) || p.isReserved(closer) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseList reads and-or lists until the end of input or one of the closers
+// of the enclosing compound command
+func (p *parser) parseList(closers ...string) (*List, error) {
+	list := &List{Position: p.tok.pos}
+
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+
+	for !p.atListEnd(closers) {
+		start := p.tok.pos
+		andOr, err := p.parseAndOr()
+		if err != nil {
+			return nil, err
+		}
+
+		item := &ListItem{
+			Position: start,
+			AndOr:    andOr,
+			Source:   strings.TrimSpace(p.src[start.Offset:p.lastEnd]),
+		}
+		list.Items = append(list.Items, item)
+
+		switch {
+		case p.isOperator("&"):
+			item.Background = true
+			fallthrough
+		case p.isOperator(";"), p.tok.kind == tokNewline:
+			if err := p.next(); err != nil {
+				return nil, err
 			}
-			continue
+		case p.atListEnd(closers):
+		default:
+			return nil, p.unexpected()
 		}
 
-		if inDoubleQuotes {
-			if character == ch1 { // closing double quote
-				inDoubleQuotes = false  
-			} else {
-				current.WriteRune(character) // spaces included
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+	}
+
+	return list, nil
+}
+
+func (p *parser) parseAndOr() (*AndOr, error) {
+	andOr := &AndOr{Position: p.tok.pos}
+
+	for {
+		pipeline, err := p.parsePipeline()
+		if err != nil {
+			return nil, err
+		}
+		andOr.Pipelines = append(andOr.Pipelines, pipeline)
+
+		if !p.isOperator("&&") && !p.isOperator("||") {
+			return andOr, nil
+		}
+		andOr.Operators = append(andOr.Operators, p.tok.value)
+
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (p *parser) parsePipeline() (*Pipeline, error) {
+	pipeline := &Pipeline{Position: p.tok.pos}
+
+	for {
+		cmd, err := p.parseCommand()
+		if err != nil {
+			return nil, err
+		}
+		pipeline.Commands = append(pipeline.Commands, cmd)
+		pipeline.Sources = append(pipeline.Sources, p.commandSource(cmd))
+
+		if !p.isOperator("|") {
+			break
+		}
+
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+	}
+
+	pipeline.Source = strings.TrimSpace(p.src[pipeline.Position.Offset:p.lastEnd])
+	return pipeline, nil
+}
+
+// commandSource returns the text of the command just parsed. The
+// redirections of a compound command are left out.
+func (p *parser) commandSource(cmd Command) string {
+	end := p.lastEnd
+	if _, simple := cmd.(*SimpleCommand); !simple {
+		if redirs := Redirects(cmd); len(redirs) > 0 {
+			end = redirs[0].Position.Offset
+		}
+	}
+	return strings.TrimSpace(p.src[cmd.Pos().Offset:end])
+}
+
+func (p *parser) parseCommand() (Command, error) {
+	switch {
+	case p.isOperator("(") && strings.HasPrefix(p.src[p.tok.pos.Offset:], "(("):
+		return p.parseArithCommand()
+	case p.isOperator("("):
+		return p.parseSubshell()
+	case p.isReserved("{"):
+		return p.parseGroup()
+	case p.isReserved("if"):
+		return p.parseIf()
+	case p.isReserved("while"), p.isReserved("until"):
+		return p.parseWhile()
+	case p.isReserved("for"):
+		return p.parseFor()
+	case p.isReserved("case"):
+		return p.parseCase()
+	case p.isReserved("function"):
+		return p.parseFunction()
+	case p.tok.kind == tokWord && p.followedByParens():
+		return p.parseFunction()
+	case p.isReservedCloser():
+		return nil, p.unexpected()
+	case p.tok.kind == tokWord, p.isRedirect():
+		return p.parseSimpleCommand()
+	}
+	return nil, p.unexpected()
+}
+
+// followedByParens reports whether the current word is followed by (),
+// which makes it the name of a function being defined
+func (p *parser) followedByParens() bool {
+	rest := strings.TrimLeft(p.src[p.tok.end:], " \t")
+	if !strings.HasPrefix(rest, "(") {
+		return false
+	}
+	return strings.HasPrefix(strings.TrimLeft(rest[1:], " \t"), ")")
+}
+
+// isReservedCloser reports whether the current token is a reserved word that
+// can only follow a list inside a compound command, eg. a fi without an if
+func (p *parser) isReservedCloser() bool {
+	for _, word := range []string{"}", "then", "elif", "else", "fi", "do", "done", "esac"} {
+		if p.isReserved(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCompoundList reads the non-empty list of a compound command up to one
+// of closers, which is left as the current token
+func (p *parser) parseCompoundList(closers ...string) (*List, error) {
+	list, err := p.parseList(closers...)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(list.Items) == 0 || p.tok.kind == tokEOF {
+		return nil, p.unexpected()
+	}
+	return list, nil
+}
+
+// parseBody reads the list of a compound command up to its closer
+func (p *parser) parseBody(closer string) (*List, error) {
+	body, err := p.parseCompoundList(closer)
+	if err != nil {
+		return nil, err
+	}
+
+	// consume the closer
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func (p *parser) parseSubshell() (*Subshell, error) {
+	subshell := &Subshell{Position: p.tok.pos}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	body, err := p.parseBody(")")
+	if err != nil {
+		return nil, err
+	}
+	subshell.Body = body
+
+	subshell.Redirs, err = p.parseRedirects()
+	if err != nil {
+		return nil, err
+	}
+	return subshell, nil
+}
+
+// parseArithCommand reads ((expr)) with the current token on the first
+// parenthesis. Like $((, (( that does not end with )) starts a subshell in
+// a subshell instead: ((cd dir; ls) | wc -l)
+func (p *parser) parseArithCommand() (Command, error) {
+	start := p.tok.pos
+	expr, err := p.lex.readArithmetic(start)
+	if perr, ok := err.(*ParseError); ok && !perr.Incomplete {
+		p.lex.offset, p.lex.line, p.lex.col = p.tok.end, start.Line, start.Col+1
+		return p.parseSubshell()
+	} else if err != nil {
+		return nil, err
+	}
+	p.tok.end = p.lex.offset
+
+	word, err := arithWord(expr, Pos{Offset: start.Offset + 2, Line: start.Line, Col: start.Col + 2})
+	if err != nil {
+		return nil, err
+	}
+	cmd := &ArithCommand{Position: start, Expr: word}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if cmd.Redirs, err = p.parseRedirects(); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+func (p *parser) parseGroup() (*Group, error) {
+	group := &Group{Position: p.tok.pos}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	body, err := p.parseBody("}")
+	if err != nil {
+		return nil, err
+	}
+	group.Body = body
+
+	group.Redirs, err = p.parseRedirects()
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+func (p *parser) parseIf() (*IfClause, error) {
+	clause := &IfClause{Position: p.tok.pos}
+
+	// if and each elif start a branch
+	for p.isReserved("if") || p.isReserved("elif") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		cond, err := p.parseCompoundList("then")
+		if err != nil {
+			return nil, err
+		}
+		if !p.isReserved("then") {
+			return nil, p.unexpected()
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+
+		body, err := p.parseCompoundList("elif", "else", "fi")
+		if err != nil {
+			return nil, err
+		}
+		clause.Branches = append(clause.Branches, &CondBranch{Cond: cond, Body: body})
+	}
+
+	if p.isReserved("else") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		body, err := p.parseCompoundList("fi")
+		if err != nil {
+			return nil, err
+		}
+		clause.Else = body
+	}
+
+	if !p.isReserved("fi") {
+		return nil, p.unexpected()
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	clause.Redirs, err = p.parseRedirects()
+	if err != nil {
+		return nil, err
+	}
+	return clause, nil
+}
+
+func (p *parser) parseWhile() (*WhileClause, error) {
+	clause := &WhileClause{Position: p.tok.pos, Until: p.isReserved("until")}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	cond, err := p.parseCompoundList("do")
+	if err != nil {
+		return nil, err
+	}
+	clause.Cond = cond
+
+	if clause.Body, err = p.parseDoGroup(); err != nil {
+		return nil, err
+	}
+	if clause.Redirs, err = p.parseRedirects(); err != nil {
+		return nil, err
+	}
+	return clause, nil
+}
+
+func (p *parser) parseFor() (Command, error) {
+	pos := p.tok.pos
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if p.isOperator("(") && strings.HasPrefix(p.src[p.tok.pos.Offset:], "((") {
+		return p.parseArithFor(pos)
+	}
+
+	if p.tok.kind != tokWord || !IsName(p.tok.value) {
+		return nil, p.unexpected()
+	}
+	clause := &ForClause{Position: pos, Name: p.tok.value}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+
+	if p.isReserved("in") {
+		clause.In = true
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		for p.tok.kind == tokWord {
+			clause.Words = append(clause.Words, ExpandBraces(p.tok.word)...)
+			if err := p.next(); err != nil {
+				return nil, err
 			}
+		}
+		if !p.isOperator(";") && p.tok.kind != tokNewline {
+			return nil, p.unexpected()
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	} else if p.isOperator(";") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
 
-			continue
+	var err error
+	if clause.Body, err = p.parseDoGroup(); err != nil {
+		return nil, err
+	}
+	if clause.Redirs, err = p.parseRedirects(); err != nil {
+		return nil, err
+	}
+	return clause, nil
+}
+
+// parseArithFor reads for ((init; cond; post)) with the current token on the
+// first parenthesis
+func (p *parser) parseArithFor(pos Pos) (*ArithForClause, error) {
+	start := p.tok.pos
+	expr, err := p.lex.readArithmetic(start)
+	if err != nil {
+		return nil, err
+	}
+	p.tok.end = p.lex.offset
+
+	parts := strings.Split(expr, ";")
+	if len(parts) != 3 {
+		return nil, &ParseError{Pos: start, Msg: "syntax error: for ((init; cond; post)) needs three expressions"}
+	}
+
+	// each expression is a word, expanded before it is evaluated
+	var words [3]*Word
+	offset := 2 // after ((
+	for i, part := range parts {
+		partPos := Pos{Offset: start.Offset + offset, Line: start.Line, Col: start.Col + offset}
+		if words[i], err = arithWord(strings.TrimSpace(part), partPos); err != nil {
+			return nil, err
+		}
+		offset += len(part) + 1
+	}
+	clause := &ArithForClause{Position: pos, Init: words[0], Cond: words[1], Post: words[2]}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if p.isOperator(";") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+
+	if clause.Body, err = p.parseDoGroup(); err != nil {
+		return nil, err
+	}
+	if clause.Redirs, err = p.parseRedirects(); err != nil {
+		return nil, err
+	}
+	return clause, nil
+}
+
+func (p *parser) parseCase() (*CaseClause, error) {
+	clause := &CaseClause{Position: p.tok.pos}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	if p.tok.kind != tokWord {
+		return nil, p.unexpected()
+	}
+	clause.Word = p.tok.word
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+
+	if !p.isReserved("in") {
+		return nil, p.unexpected()
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
+
+	for !p.isReserved("esac") {
+		item, err := p.parseCaseItem()
+		if err != nil {
+			return nil, err
+		}
+		clause.Items = append(clause.Items, item)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if clause.Redirs, err = p.parseRedirects(); err != nil {
+		return nil, err
+	}
+	return clause, nil
+}
+
+// parseCaseItem reads pat1|pat2) list ;; up to the next item or esac
+func (p *parser) parseCaseItem() (*CaseItem, error) {
+	item := &CaseItem{Position: p.tok.pos}
+
+	if p.isOperator("(") {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		if p.tok.kind != tokWord {
+			return nil, p.unexpected()
+		}
+		item.Patterns = append(item.Patterns, p.tok.word)
+		if err := p.next(); err != nil {
+			return nil, err
 		}
 
-		
-	// Outside quotes: opening quotes
-    if character == '\'' {
-        inSingleQuotes = true
-        continue
-    }
+		if !p.isOperator("|") {
+			break
+		}
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
+
+	if !p.isOperator(")") {
+		return nil, p.unexpected()
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+
+	body, err := p.parseList(";;", ";&", ";;&", "esac")
+	if err != nil {
+		return nil, err
+	}
+	item.Body = body
+
+	switch {
+	case p.isOperator(";;"), p.isOperator(";&"), p.isOperator(";;&"):
+		item.Terminator = p.tok.value
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		if err := p.skipNewlines(); err != nil {
+			return nil, err
+		}
+	case p.isReserved("esac"):
+		item.Terminator = ";;" // the last item may leave it out
+	default:
+		return nil, p.unexpected()
+	}
+	return item, nil
+}
+
+// parseFunction reads a function definition, from its name or from the
+// function keyword
+func (p *parser) parseFunction() (*FunctionDef, error) {
+	def := &FunctionDef{Position: p.tok.pos}
+
+	keyword := p.isReserved("function")
+	if keyword {
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+	}
 
-	    if character == '"' {
-        inDoubleQuotes = true
-        continue
-    }
+	if p.tok.kind != tokWord || !isFunctionName(p.tok.word) {
+		return nil, p.unexpected()
+	}
+	def.Name = p.tok.value
+	if err := p.next(); err != nil {
+		return nil, err
+	}
 
-    // 6) Outside quotes: space ends a token
-    if character == ' ' {
-        if current.Len() > 0 {
-            tokens = append(tokens, current.String())
-            current.Reset()
-        }
-        continue
-    }
+	// the parentheses are optional after the function keyword
+	if p.isOperator("(") || !keyword {
+		for _, paren := range []string{"(", ")"} {
+			if !p.isOperator(paren) {
+				return nil, p.unexpected()
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := p.skipNewlines(); err != nil {
+		return nil, err
+	}
 
-    // 7) Normal character outside quotes
-    current.WriteRune(character)
+	body, err := p.parseCommand()
+	if err != nil {
+		return nil, err
 	}
+	switch body.(type) {
+	case *SimpleCommand, *FunctionDef:
+		return nil, &ParseError{Pos: body.Pos(), Msg: "syntax error: the body of function " + def.Name + " must be a compound command such as { ...; }"}
+	}
+	def.Body = body
+	def.Source = strings.TrimSpace(p.src[def.Position.Offset:p.lastEnd])
+	return def, nil
+}
 
-	if current.Len() > 0 {
-    tokens = append(tokens, current.String())
+// isFunctionName reports whether a word can name a function: unquoted text
+// without expansions, eg. build or git-sync
+func isFunctionName(word *Word) bool {
+	if len(word.Parts) != 1 {
+		return false
+	}
+	lit, ok := word.Parts[0].(*Lit)
+	return ok && lit.Value != "" && !strings.ContainsAny(lit.Value, "$`\\/=")
+}
+
+// parseDoGroup reads the body of a loop: do list done
+func (p *parser) parseDoGroup() (*List, error) {
+	if !p.isReserved("do") {
+		return nil, p.unexpected()
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	return p.parseBody("done")
 }
 
-if inSingleQuotes || inDoubleQuotes {
-    return nil, fmt.Errorf("unclosed quote in input")
+// parseRedirects reads the redirections following a compound command
+func (p *parser) parseRedirects() ([]*Redirect, error) {
+	var redirs []*Redirect
+	for p.isRedirect() {
+		redir, err := p.parseRedirect()
+		if err != nil {
+			return nil, err
+		}
+		redirs = append(redirs, redir)
+	}
+	return redirs, nil
 }
 
-if backlash {
-        return nil, fmt.Errorf("trailing backslash at end of input")
-    }
+func (p *parser) parseRedirect() (*Redirect, error) {
+	redir := &Redirect{Position: p.tok.pos, Fd: p.tok.fd, Op: p.tok.value}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
 
-return tokens, nil
-}	
\ No newline at end of file
+	if p.tok.kind != tokWord {
+		return nil, p.unexpected()
+	}
+	redir.Target = p.tok.word
+
+	// the body is read by the lexer once it reaches the end of the line
+	if redir.Op == "<<" || redir.Op == "<<-" {
+		delimiter, quoted := hereDocDelimiter(p.tok.value)
+		p.lex.hereDocs = append(p.lex.hereDocs, &hereDoc{redir: redir, delimiter: delimiter, quoted: quoted})
+	}
+
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	return redir, nil
+}
+
+func (p *parser) parseSimpleCommand() (*SimpleCommand, error) {
+	cmd := &SimpleCommand{Position: p.tok.pos}
+
+	for {
+		switch {
+		case p.isRedirect():
+			redir, err := p.parseRedirect()
+			if err != nil {
+				return nil, err
+			}
+			cmd.Redirs = append(cmd.Redirs, redir)
+			continue
+
+		case p.tok.kind == tokWord:
+			// NAME=value words are assignments until the command name shows up
+			if assign := asAssignment(p.tok.word); assign != nil && len(cmd.Args) == 0 {
+				cmd.Assigns = append(cmd.Assigns, assign)
+			} else {
+				cmd.Args = append(cmd.Args, ExpandBraces(p.tok.word)...)
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		break
+	}
+
+	return cmd, nil
+}
+
+// asAssignment returns the assignment written by a word like NAME=value, or
+// nil when the word is not one
+func asAssignment(word *Word) *Assign {
+	if len(word.Parts) == 0 {
+		return nil
+	}
+	lit, ok := word.Parts[0].(*Lit)
+	if !ok {
+		return nil
+	}
+
+	eq := strings.IndexByte(lit.Value, '=')
+	if eq <= 0 || !IsName(lit.Value[:eq]) {
+		return nil
+	}
+
+	value := &Word{Position: word.Position}
+	value.Position.Offset += eq + 1
+	value.Position.Col += eq + 1
+	if rest := lit.Value[eq+1:]; rest != "" {
+		value.Parts = append(value.Parts, &Lit{Value: rest})
+	}
+	value.Parts = append(value.Parts, word.Parts[1:]...)
+
+	return &Assign{Position: word.Position, Name: lit.Value[:eq], Value: value}
+}
+
+// IsName reports whether s is a valid variable name: a letter or underscore
+// followed by letters, digits and underscores
+func IsName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		isLetter := (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_'
+		if !isLetter && (i == 0 || ch < '0' || ch > '9') {
+			return false
+		}
+	}
+	return true
+}
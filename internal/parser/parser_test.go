@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseLists(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string // each item: its pipelines joined by their operators, & when in the background
+	}{
+		{"ls", []string{"ls"}},
+		{"a; b", []string{"a", "b"}},
+		{"a\nb\n\nc", []string{"a", "b", "c"}},
+		{"a && b || c", []string{"a && b || c"}},
+		{"a | b && c | d", []string{"a | b && c | d"}},
+		{"sleep 2 && echo done &", []string{"sleep 2 && echo done &"}},
+		{"a & b", []string{"a &", "b"}},
+		{"(cd dir; ls) | wc -l; echo", []string{"(cd dir; ls) | wc -l", "echo"}},
+		{"if true; then echo a; fi > out &", []string{"if true; then echo a; fi > out &"}},
+		{"a &&\nb", []string{"a && b"}},
+		{"a |\n b", []string{"a |\n b"}}, // the text as written
+	}
+
+	for _, tt := range tests {
+		list, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error: %v", tt.src, err)
+			continue
+		}
+
+		var got []string
+		for _, item := range list.Items {
+			var sb strings.Builder
+			for i, pipeline := range item.AndOr.Pipelines {
+				if i > 0 {
+					sb.WriteString(" " + item.AndOr.Operators[i-1] + " ")
+				}
+				sb.WriteString(pipeline.Source)
+			}
+			if item.Background {
+				sb.WriteString(" &")
+			}
+			got = append(got, sb.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("Parse(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		src        string
+		incomplete bool // more lines could complete the input
+	}{
+		{"a &&", true},
+		{"a |", true},
+		{"if true; then", true},
+		{"while true; do echo", true},
+		{"echo 'unclosed", true},
+		{`echo "unclosed`, true},
+		{"echo $(ls", true},
+		{"cat <<EOF\nbody", true},
+		{"a && && b", false},
+		{"| a", false},
+		{"a ;; b", false},
+		{"fi", false},
+		{"(a", true},
+		{"a )", false},
+	}
+
+	for _, tt := range tests {
+		_, err := Parse(tt.src)
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Errorf("Parse(%q): error %v, want a *ParseError", tt.src, err)
+			continue
+		}
+		if perr.Incomplete != tt.incomplete {
+			t.Errorf("Parse(%q): Incomplete = %v, want %v (%v)", tt.src, perr.Incomplete, tt.incomplete, err)
+		}
+	}
+}
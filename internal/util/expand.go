@@ -0,0 +1,398 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"simple_sh/internal/arith"
+	"simple_sh/internal/parser"
+	"strconv"
+	"strings"
+)
+
+// Words are expanded when the command using them runs, not when the line is
+// read, so a variable set earlier in the same line or in an enclosing
+// compound command is already visible:
+//
+//	x=1; if true; then x=2; echo $x; fi     prints 2
+//
+// A ~ starting a word is the home directory, ~user the one of user. Then
+// variables are expanded in unquoted and double-quoted parts, single-quoted
+// parts are kept as they are, and quotes and backslashes are removed.
+//
+// An expansion can fail, eg. $((1/0)): the command using the word must not
+// run then.
+
+// CommandSubstitution runs the body of $(...) or `...` and returns what it
+// wrote on its standard output. The executor provides it.
+var CommandSubstitution func(body *parser.List) string
+
+// ExpandWords expands the words of a command into its arguments. The value
+// of an unquoted variable is split into several arguments on the characters
+// of $IFS, and one that expands to nothing disappears: with x="a b", ls $x
+// runs ls a b. "$@" gives one argument per positional parameter.
+//
+// Then each argument holding an unquoted *, ? or [ is replaced with the
+// files it matches: ls *.txt "$dir"/*.log
+func ExpandWords(words []*parser.Word) ([]string, error) {
+	e := expansion{glob: true}
+	for _, word := range words {
+		e.word(expandTilde(word.Parts), false)
+		e.endField()
+	}
+	return e.fields, e.err
+}
+
+// ExpandWord expands a word that stays a single string whatever its value,
+// like the value of an assignment. $@ joins the positional parameters with
+// spaces there.
+func ExpandWord(word *parser.Word) (string, error) {
+	var e expansion
+	e.noSplit = true
+	e.word(expandTilde(word.Parts), false)
+	return e.current.String(), e.err
+}
+
+// ExpandTarget expands the target of a redirection. Like an argument it is
+// split and globbed, and it must come out as a single field.
+func ExpandTarget(word *parser.Word) (string, error) {
+	fields, err := ExpandWords([]*parser.Word{word})
+	if err != nil {
+		return "", err
+	}
+	if len(fields) != 1 {
+		return "", fmt.Errorf("ambiguous redirect")
+	}
+	return fields[0], nil
+}
+
+// ExpandPattern expands a word used as a pattern, eg. by case. Quoted
+// characters come out escaped with a backslash so they match literally:
+// with x=*, "$x" only matches a *, $x matches anything.
+func ExpandPattern(word *parser.Word) (string, error) {
+	e := expansion{noSplit: true, glob: true}
+	e.word(expandTilde(word.Parts), false)
+	return e.pattern.String(), e.err
+}
+
+// ExpandArithmetic expands the variables and command substitutions of an
+// arithmetic expression, then evaluates it
+func ExpandArithmetic(expr *parser.Word) (int64, error) {
+	e := expansion{noSplit: true}
+	e.word(expr.Parts, true)
+	if e.err != nil {
+		return 0, e.err
+	}
+	return EvalArithmetic(e.current.String())
+}
+
+// EvalArithmetic evaluates an arithmetic expression on the shell variables
+func EvalArithmetic(expr string) (int64, error) {
+	return arith.Eval(expr, arithEnv{})
+}
+
+// arithEnv gives arithmetic expressions access to the shell variables
+type arithEnv struct{}
+
+func (arithEnv) Get(name string) string {
+	return GetVariable(name)
+}
+
+func (arithEnv) Set(name, value string) {
+	SetVariable(name, value)
+}
+
+// expansion collects the fields produced by the words being expanded
+type expansion struct {
+	fields  []string
+	current strings.Builder
+	inField bool // current holds a field, even an empty one like ""
+	noSplit bool
+	err     error // the first expansion that failed
+
+	// With glob set, pattern holds current with its quoted glob characters
+	// escaped, and hasGlob tells whether it has unquoted ones
+	glob    bool
+	pattern strings.Builder
+	hasGlob bool
+}
+
+func (e *expansion) endField() {
+	if e.inField {
+		if e.hasGlob && !Option("noglob") {
+			e.fields = append(e.fields, e.matches()...)
+		} else {
+			e.fields = append(e.fields, e.current.String())
+		}
+	}
+	e.current.Reset()
+	e.pattern.Reset()
+	e.inField = false
+	e.hasGlob = false
+}
+
+// matches returns the files matched by the current field. When there are
+// none it returns the field itself, nothing with nullglob, and fails with
+// failglob.
+func (e *expansion) matches() []string {
+	matches := Glob(e.pattern.String())
+	if len(matches) > 0 {
+		return matches
+	}
+
+	switch {
+	case Option("failglob"):
+		e.fail(fmt.Errorf("no match: %s", e.current.String()))
+		return nil
+	case Option("nullglob"):
+		return nil
+	}
+	return []string{e.current.String()}
+}
+
+// write adds quoted text, or text that came out of a backslash escape
+func (e *expansion) write(s string) {
+	e.current.WriteString(s)
+	e.inField = true
+	if e.glob {
+		for i := 0; i < len(s); i++ {
+			if strings.IndexByte("*?[\\", s[i]) >= 0 {
+				e.pattern.WriteByte('\\')
+			}
+			e.pattern.WriteByte(s[i])
+		}
+	}
+}
+
+// writeUnquoted adds unquoted text, where glob characters are special
+func (e *expansion) writeUnquoted(ch byte) {
+	e.current.WriteByte(ch)
+	e.inField = true
+	if e.glob {
+		e.pattern.WriteByte(ch)
+		if strings.IndexByte("*?[", ch) >= 0 {
+			e.hasGlob = true
+		}
+	}
+}
+
+// writeSplit adds the value of an unquoted expansion, split into fields on
+// the characters of $IFS, space, tab and newline when it is unset.
+//
+// Runs of IFS blanks separate fields and are dropped at both ends, while
+// every other IFS character ends a field, even an empty one: with IFS=:,
+// "a::b" splits into a, "" and b.
+func (e *expansion) writeSplit(value string) {
+	ifs, set := lookupParameter("IFS")
+	if !set {
+		ifs = " \t\n"
+	}
+
+	if e.noSplit || ifs == "" {
+		for i := 0; i < len(value); i++ {
+			e.writeUnquoted(value[i])
+		}
+		return
+	}
+
+	// afterDelimiter is set when a field ended at a non-blank delimiter, or
+	// nothing precedes the value: another one there delimits an empty field
+	afterDelimiter := !e.inField
+	for i := 0; i < len(value); i++ {
+		ch := value[i]
+		switch {
+		case strings.IndexByte(ifs, ch) < 0:
+			e.writeUnquoted(ch)
+			afterDelimiter = false
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			e.endField()
+		default:
+			if afterDelimiter && !e.inField {
+				e.inField = true
+			}
+			e.endField()
+			afterDelimiter = true
+		}
+	}
+}
+
+// positional expands $@ and $*. Each parameter is a separate field, except
+// in "$*" which joins them with the first character of $IFS. "$@" with no
+// parameters gives no field at all, not an empty one.
+func (e *expansion) positional(name string, inDoubleQuotes bool) {
+	args := positionalArgs
+
+	if inDoubleQuotes && name == "*" {
+		ifs, set := lookupParameter("IFS")
+		separator := " "
+		if set {
+			separator = ifs[:min(1, len(ifs))]
+		}
+		e.write(strings.Join(args, separator))
+		return
+	}
+
+	for i, arg := range args {
+		switch {
+		case i == 0:
+		case e.noSplit:
+			e.write(" ") // a single field, as in x="$@"
+		default:
+			if inDoubleQuotes {
+				e.inField = true
+			}
+			e.endField()
+		}
+		e.emit(arg, inDoubleQuotes)
+	}
+}
+
+// expandTilde replaces the ~ or ~user starting a word with the home
+// directory, quoted so it is neither split nor globbed. The prefix goes up
+// to the first /, and nothing in it may be quoted: "~" and ~"user" stay.
+func expandTilde(parts []parser.WordPart) []parser.WordPart {
+	if len(parts) == 0 {
+		return parts
+	}
+	lit, ok := parts[0].(*parser.Lit)
+	if !ok || !strings.HasPrefix(lit.Value, "~") {
+		return parts
+	}
+
+	prefix, rest, hasSlash := strings.Cut(lit.Value, "/")
+	if !hasSlash && len(parts) > 1 || strings.ContainsAny(prefix, "\\$`") {
+		return parts
+	}
+
+	var home string
+	if prefix == "~" {
+		var set bool
+		if home, set = lookupParameter("HOME"); !set {
+			home, _ = os.UserHomeDir()
+		}
+	} else if u, err := user.Lookup(prefix[1:]); err == nil {
+		home = u.HomeDir
+	}
+	if home == "" {
+		return parts
+	}
+
+	expanded := []parser.WordPart{&parser.SglQuoted{Value: home}}
+	if hasSlash {
+		expanded = append(expanded, &parser.Lit{Value: "/" + rest})
+	}
+	return append(expanded, parts[1:]...)
+}
+
+// isEmptyAt reports whether double-quoted parts are just $@ while there are
+// no positional parameters, so "$@" disappears like an unquoted expansion
+func isEmptyAt(parts []parser.WordPart) bool {
+	if len(positionalArgs) > 0 || len(parts) != 1 {
+		return false
+	}
+	switch p := parts[0].(type) {
+	case *parser.Lit:
+		return p.Value == "$@"
+	case *parser.ParamExp:
+		return p.Name == "@" && p.Op == "" && !p.Length
+	}
+	return false
+}
+
+func (e *expansion) word(parts []parser.WordPart, inDoubleQuotes bool) {
+	for _, part := range parts {
+		if e.err != nil {
+			return
+		}
+
+		switch p := part.(type) {
+		case *parser.SglQuoted:
+			e.write(p.Value)
+		case *parser.DblQuoted:
+			if !isEmptyAt(p.Parts) {
+				e.inField = true // "" is an empty argument
+			}
+			e.word(p.Parts, true)
+		case *parser.Lit:
+			e.literal(p.Value, inDoubleQuotes)
+		case *parser.CmdSubst:
+			e.substitute(p, inDoubleQuotes)
+		case *parser.ArithExp:
+			value, err := ExpandArithmetic(p.Expr)
+			if err != nil {
+				e.err = err
+				return
+			}
+			e.write(strconv.FormatInt(value, 10))
+		case *parser.ParamExp:
+			e.parameter(p, inDoubleQuotes)
+		}
+	}
+}
+
+// substitute adds the output of a command substitution without its trailing
+// newlines. Unless quoted it is split into fields like a variable: with
+// files a and b, ls $(ls) runs ls a b but ls "$(ls)" runs ls $'a\nb'.
+func (e *expansion) substitute(subst *parser.CmdSubst, inDoubleQuotes bool) {
+	output := strings.TrimRight(CommandSubstitution(subst.Body), "\n")
+	e.emit(output, inDoubleQuotes)
+}
+
+// literal expands the variables of unquoted or double-quoted text and
+// resolves its backslash escapes
+func (e *expansion) literal(s string, inDoubleQuotes bool) {
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+
+		if ch == '\\' && i+1 < len(s) {
+			next := s[i+1]
+			if !inDoubleQuotes || strings.IndexByte("$`\"\\\n", next) >= 0 {
+				i++
+				if next != '\n' { // backslash-newline is a line continuation
+					e.write(string(next))
+				}
+				continue
+			}
+		}
+
+		if ch == '$' && i+1 < len(s) && (s[i+1] == '@' || s[i+1] == '*') {
+			e.positional(s[i+1:i+2], inDoubleQuotes)
+			i++
+			continue
+		}
+
+		if ch == '$' {
+			value, length := expandDollar(s[i+1:])
+			if length > 0 {
+				e.emit(value, inDoubleQuotes)
+				i += length
+				continue
+			}
+		}
+
+		// unquoted blanks only get here from the word of ${x:-a b}, and
+		// separate fields there too
+		if !inDoubleQuotes && !e.noSplit && (ch == ' ' || ch == '\t' || ch == '\n') {
+			e.endField()
+			continue
+		}
+
+		if inDoubleQuotes {
+			e.write(string(ch))
+		} else {
+			e.writeUnquoted(ch)
+		}
+	}
+}
+
+// expandDollar expands the variable reference following a $: NAME or a
+// special parameter, ${...} is a parser.ParamExp. It returns its value and
+// how many bytes it used, 0 when the $ does not start a reference and stays
+// as it is.
+func expandDollar(s string) (string, int) {
+	name := extractVarName(s, 0)
+	if name == "" {
+		return "", 0
+	}
+	return lookupVariable(name), len(name)
+}
@@ -0,0 +1,176 @@
+package util
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// Glob returns the files matching a pattern, sorted. The pattern is matched
+// one path component at a time with MatchPattern, and quoted characters
+// come escaped with a backslash, see ExpandPattern.
+//
+// Like in other shells, names starting with a dot only match a component
+// starting with a dot, unless dotglob is on. With globstar, a ** component
+// matches any number of directories: **/*.go finds the Go files of every
+// subdirectory.
+func Glob(pattern string) []string {
+	prefix := ""
+	if strings.HasPrefix(pattern, "/") {
+		prefix = "/"
+		pattern = strings.TrimLeft(pattern, "/")
+	}
+
+	matches := globIn(prefix, strings.Split(pattern, "/"))
+	sort.Strings(matches)
+	return matches
+}
+
+// globIn matches the components left in parts below the directory prefix,
+// "" for the current directory
+func globIn(prefix string, parts []string) []string {
+	if len(parts) == 0 {
+		return []string{prefix}
+	}
+	part, rest := parts[0], parts[1:]
+
+	switch {
+	case part == "":
+		// a trailing slash only keeps directories, and prefix is one
+		if len(rest) == 0 {
+			return []string{prefix + "/"}
+		}
+		return globIn(prefix, rest)
+
+	case !hasGlobChars(part):
+		path := joinPath(prefix, unescapePattern(part))
+		if len(rest) == 0 {
+			if _, err := os.Lstat(path); err != nil {
+				return nil
+			}
+			return []string{path}
+		}
+		if !isDir(path) {
+			return nil
+		}
+		return globIn(path, rest)
+
+	case part == "**" && Option("globstar"):
+		return globStar(prefix, rest)
+	}
+
+	var matches []string
+	for _, name := range readDirNames(prefix) {
+		if !visible(name, part) || !matchName(part, name) {
+			continue
+		}
+		path := joinPath(prefix, name)
+		if len(rest) == 0 {
+			matches = append(matches, path)
+		} else if isDir(path) {
+			matches = append(matches, globIn(path, rest)...)
+		}
+	}
+	return matches
+}
+
+// globStar matches ** followed by rest: rest below prefix, or below any of
+// its subdirectories. A ** at the end matches every file and directory.
+func globStar(prefix string, rest []string) []string {
+	var matches []string
+	if len(rest) > 0 {
+		matches = globIn(prefix, rest)
+	}
+
+	for _, name := range readDirNames(prefix) {
+		if !visible(name, "*") {
+			continue
+		}
+		path := joinPath(prefix, name)
+		if len(rest) == 0 {
+			matches = append(matches, path)
+		}
+
+		// symbolic links to directories are not followed, so a link to
+		// a parent directory does not loop forever
+		if info, err := os.Lstat(path); err == nil && info.IsDir() {
+			matches = append(matches, globStar(path, rest)...)
+		}
+	}
+	return matches
+}
+
+// visible reports whether a pattern component may match name: a hidden name
+// needs a pattern starting with a dot, or dotglob, which never matches . and ..
+func visible(name, pattern string) bool {
+	if !strings.HasPrefix(name, ".") || strings.HasPrefix(pattern, ".") {
+		return true
+	}
+	return Option("dotglob") && name != "." && name != ".."
+}
+
+func matchName(pattern, name string) bool {
+	if Option("nocaseglob") {
+		return MatchPattern(strings.ToLower(pattern), strings.ToLower(name))
+	}
+	return MatchPattern(pattern, name)
+}
+
+func readDirNames(dir string) []string {
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func joinPath(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	if strings.HasSuffix(dir, "/") {
+		return dir + name
+	}
+	return dir + "/" + name
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// hasGlobChars reports whether a pattern has an unescaped *, ? or [
+func hasGlobChars(pattern string) bool {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
+
+// unescapePattern removes the backslashes of a pattern without glob
+// characters, giving the name it stands for
+func unescapePattern(pattern string) string {
+	if !strings.Contains(pattern, "\\") {
+		return pattern
+	}
+	var sb strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '\\' && i+1 < len(pattern) {
+			i++
+		}
+		sb.WriteByte(pattern[i])
+	}
+	return sb.String()
+}
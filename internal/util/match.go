@@ -0,0 +1,152 @@
+package util
+
+import (
+	"unicode"
+)
+
+// MatchPattern reports whether s matches the shell pattern, as case does:
+// * matches any string, ? any single character, [...] one character of a
+// set such as [abc], [a-z] or [[:digit:]], negated with [!...] or [^...],
+// and a backslash takes the next character literally.
+func MatchPattern(pattern, s string) bool {
+	p, str := []rune(pattern), []rune(s)
+	px, sx := 0, 0
+
+	// where to resume after the last * when the rest fails to match: the
+	// star then swallows one more character
+	starPx, starSx := -1, -1
+
+	for px < len(p) || sx < len(str) {
+		if px < len(p) {
+			switch ch := p[px]; ch {
+			case '*':
+				starPx, starSx = px, sx+1
+				px++
+				continue
+
+			case '?':
+				if sx < len(str) {
+					px++
+					sx++
+					continue
+				}
+
+			case '[':
+				if sx < len(str) {
+					matched, width, ok := matchBracket(p[px:], str[sx])
+					if !ok {
+						// no closing bracket, the [ is an ordinary character
+						matched, width = str[sx] == '[', 1
+					}
+					if matched {
+						px += width
+						sx++
+						continue
+					}
+				}
+
+			case '\\':
+				if px+1 < len(p) {
+					px++
+					ch = p[px]
+				}
+				fallthrough
+
+			default:
+				if sx < len(str) && str[sx] == ch {
+					px++
+					sx++
+					continue
+				}
+			}
+		}
+
+		if starPx >= 0 && starSx <= len(str) {
+			px, sx = starPx, starSx
+			continue
+		}
+		return false
+	}
+
+	return true
+}
+
+// characterClasses are the names accepted in [[:name:]]
+var characterClasses = map[string]func(rune) bool{
+	"alnum":  func(r rune) bool { return unicode.IsLetter(r) || unicode.IsDigit(r) },
+	"alpha":  unicode.IsLetter,
+	"blank":  func(r rune) bool { return r == ' ' || r == '\t' },
+	"cntrl":  unicode.IsControl,
+	"digit":  unicode.IsDigit,
+	"graph":  func(r rune) bool { return unicode.IsGraphic(r) && !unicode.IsSpace(r) },
+	"lower":  unicode.IsLower,
+	"print":  unicode.IsPrint,
+	"punct":  unicode.IsPunct,
+	"space":  unicode.IsSpace,
+	"upper":  unicode.IsUpper,
+	"xdigit": func(r rune) bool { return unicode.Is(unicode.ASCII_Hex_Digit, r) },
+}
+
+// matchBracket matches r against the bracket expression at the start of p.
+// It returns whether r is in the set and the length of the expression, or
+// ok false when the expression has no closing bracket.
+func matchBracket(p []rune, r rune) (matched bool, width int, ok bool) {
+	i := 1
+	negate := false
+	if i < len(p) && (p[i] == '!' || p[i] == '^') {
+		negate = true
+		i++
+	}
+
+	// a ] right after the opening bracket is part of the set
+	for first := true; i < len(p); first = false {
+		if p[i] == ']' && !first {
+			return matched != negate, i + 1, true
+		}
+
+		// [:class:]
+		if p[i] == '[' && i+1 < len(p) && p[i+1] == ':' {
+			if end := indexRunes(p[i+2:], ":]"); end >= 0 {
+				if class, known := characterClasses[string(p[i+2:i+2+end])]; known && class(r) {
+					matched = true
+				}
+				i += end + 4
+				continue
+			}
+		}
+
+		lo := p[i]
+		if lo == '\\' && i+1 < len(p) {
+			i++
+			lo = p[i]
+		}
+		i++
+
+		hi := lo
+		if i+1 < len(p) && p[i] == '-' && p[i+1] != ']' {
+			hi = p[i+1]
+			if hi == '\\' && i+2 < len(p) {
+				i++
+				hi = p[i+1]
+			}
+			i += 2
+		}
+
+		if lo <= r && r <= hi {
+			matched = true
+		}
+	}
+
+	return false, 0, false
+}
+
+// indexRunes returns the index of sub in p, or -1
+func indexRunes(p []rune, sub string) int {
+	s := []rune(sub)
+	for i := 0; i+len(s) <= len(p); i++ {
+		if string(p[i:i+len(s)]) == sub {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,58 @@
+package util
+
+import "testing"
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern, s string
+		want       bool
+	}{
+		{"", "", true},
+		{"", "a", false},
+		{"abc", "abc", true},
+		{"abc", "abd", false},
+
+		{"*", "", true},
+		{"*", "anything", true},
+		{"a*", "abc", true},
+		{"*c", "abc", true},
+		{"a*c", "ac", true},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"*.go", "main.go", true},
+		{"*.go", "main.go.txt", false},
+
+		{"?", "a", true},
+		{"?", "", false},
+		{"a?c", "abc", true},
+		{"a?c", "ac", false},
+		{"??", "é!", true}, // characters, not bytes
+
+		{"[abc]", "b", true},
+		{"[abc]", "d", false},
+		{"[a-z]x", "qx", true},
+		{"[a-z]", "Q", false},
+		{"[!a-z]", "Q", true},
+		{"[^a-z]", "q", false},
+		{"[]a]", "]", true},
+		{"[!]a]", "]", false},
+		{"[a-]", "-", true},
+		{"[[:digit:]]*", "7up", true},
+		{"[[:alpha:]]", "7", false},
+		{"[[:upper:][:digit:]]", "Q", true},
+		{"[[:space:]]", " ", true},
+		{"[abc", "[abc", true}, // no closing bracket
+		{"[abc", "a", false},
+
+		{`\*`, "*", true},
+		{`\*`, "a", false},
+		{`a\?`, "a?", true},
+		{`\[a]`, "[a]", true},
+	}
+
+	for _, tt := range tests {
+		if got := MatchPattern(tt.pattern, tt.s); got != tt.want {
+			t.Errorf("MatchPattern(%q, %q) = %v, want %v", tt.pattern, tt.s, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package util
+
+import (
+	"fmt"
+)
+
+// Shell options, turned on and off with shopt -s/-u or set -o/+o
+//
+//	dotglob     patterns match files starting with a dot
+//	failglob    a pattern matching nothing is an error
+//	globstar    ** matches files in any subdirectory
+//	nocaseglob  patterns ignore case
+//	nullglob    a pattern matching nothing is removed
+//	noglob      patterns are not expanded at all (set -f)
+var options = map[string]bool{}
+
+// shoptOptions are the options of shopt, the others belong to set -o.
+// Both lists are sorted.
+var shoptOptions = []string{"dotglob", "failglob", "globstar", "nocaseglob", "nullglob"}
+var setOptions = []string{"noglob"}
+
+// Option reports whether a shell option is on
+func Option(name string) bool {
+	return options[name]
+}
+
+// SetOption turns the shopt option name on or off
+func SetOption(name string, on bool) error {
+	return setIn(shoptOptions, name, on)
+}
+
+// SetSetOption turns the set -o option name on or off
+func SetSetOption(name string, on bool) error {
+	return setIn(setOptions, name, on)
+}
+
+func setIn(names []string, name string, on bool) error {
+	for _, known := range names {
+		if known == name {
+			options[name] = on
+			return nil
+		}
+	}
+	return fmt.Errorf("%s: invalid option name", name)
+}
+
+// OptionNames returns the names of the shopt options, sorted
+func OptionNames() []string {
+	return shoptOptions
+}
+
+// SetOptionNames returns the names of the set -o options, sorted
+func SetOptionNames() []string {
+	return setOptions
+}
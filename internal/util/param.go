@@ -0,0 +1,276 @@
+package util
+
+import (
+	"fmt"
+	"simple_sh/internal/parser"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// parameter expands ${...}, see parser.ParamExp for the forms it takes.
+// The words of :- and :+ expand in place, in the same quoting as the
+// expansion itself: with x unset, ${x:-a b} gives two fields and
+// "${x:-a b}" one.
+func (e *expansion) parameter(exp *parser.ParamExp, inDoubleQuotes bool) {
+	value, set := lookupParameter(exp.Name)
+	if exp.Length {
+		length := utf8.RuneCountInString(value)
+		if exp.Name == "@" || exp.Name == "*" {
+			length = len(positionalArgs) // the number of parameters, like $#
+		}
+		e.emit(fmt.Sprint(length), inDoubleQuotes)
+		return
+	}
+
+	// with a colon, an empty value counts as unset
+	missing := !set || strings.HasPrefix(exp.Op, ":") && value == ""
+
+	switch exp.Op {
+	case "":
+		if exp.Name == "@" || exp.Name == "*" {
+			e.positional(exp.Name, inDoubleQuotes)
+			return
+		}
+		e.emit(value, inDoubleQuotes)
+
+	case ":-", "-":
+		if missing {
+			e.word(exp.Arg.Parts, inDoubleQuotes)
+		} else {
+			e.emit(value, inDoubleQuotes)
+		}
+
+	case ":+", "+":
+		if !missing {
+			e.word(exp.Arg.Parts, inDoubleQuotes)
+		}
+
+	case ":=", "=":
+		if missing {
+			if !parser.IsName(exp.Name) {
+				e.fail(fmt.Errorf("$%s: cannot assign in this way", exp.Name))
+				return
+			}
+			value = e.text(exp.Arg)
+			SetVariable(exp.Name, value)
+		}
+		e.emit(value, inDoubleQuotes)
+
+	case ":?", "?":
+		if missing {
+			msg := e.text(exp.Arg)
+			if msg == "" && exp.Op == "?" {
+				msg = "parameter not set"
+			} else if msg == "" {
+				msg = "parameter null or not set"
+			}
+			e.fail(fmt.Errorf("%s: %s", exp.Name, msg))
+			return
+		}
+		e.emit(value, inDoubleQuotes)
+
+	case "#", "##", "%", "%%":
+		e.emit(removeMatch(value, e.patternText(exp.Arg), exp.Op), inDoubleQuotes)
+
+	case "/", "//", "/#", "/%":
+		replacement := ""
+		if exp.Arg2 != nil {
+			replacement = e.text(exp.Arg2)
+		}
+		e.emit(replaceMatches(value, e.patternText(exp.Arg), replacement, exp.Op), inDoubleQuotes)
+
+	case "^^", "^", ",,", ",":
+		pattern := "?"
+		if len(exp.Arg.Parts) > 0 {
+			pattern = e.patternText(exp.Arg)
+		}
+		e.emit(convertCase(value, pattern, exp.Op), inDoubleQuotes)
+
+	case ":":
+		e.emit(e.substring(value, exp), inDoubleQuotes)
+	}
+}
+
+// emit adds the value of a parameter, split into fields unless quoted
+func (e *expansion) emit(value string, inDoubleQuotes bool) {
+	if inDoubleQuotes {
+		e.write(value)
+	} else {
+		e.writeSplit(value)
+	}
+}
+
+// fail records the first expansion error, the command must not run
+func (e *expansion) fail(err error) {
+	if e.err == nil {
+		e.err = err
+	}
+}
+
+// text expands the word of an operator into a single string
+func (e *expansion) text(word *parser.Word) string {
+	sub := expansion{noSplit: true}
+	sub.word(word.Parts, false)
+	if sub.err != nil {
+		e.fail(sub.err)
+	}
+	return sub.current.String()
+}
+
+// patternText expands the word of an operator used as a pattern, where
+// quoted characters match literally
+func (e *expansion) patternText(word *parser.Word) string {
+	sub := expansion{noSplit: true, glob: true}
+	sub.word(word.Parts, false)
+	if sub.err != nil {
+		e.fail(sub.err)
+	}
+	return sub.pattern.String()
+}
+
+// substring implements ${name:offset:length}. Both are arithmetic
+// expressions; a negative offset counts from the end, and so does a negative
+// length: ${x: -3} and ${x:1:-1}
+func (e *expansion) substring(value string, exp *parser.ParamExp) string {
+	runes := []rune(value)
+
+	offset, err := EvalArithmetic(e.text(exp.Arg))
+	if err != nil {
+		e.fail(err)
+		return ""
+	}
+	if offset < 0 {
+		offset += int64(len(runes))
+	}
+	if offset < 0 || offset > int64(len(runes)) {
+		return ""
+	}
+
+	end := int64(len(runes))
+	if exp.Arg2 != nil {
+		length, err := EvalArithmetic(e.text(exp.Arg2))
+		if err != nil {
+			e.fail(err)
+			return ""
+		}
+		if length < 0 {
+			end += length
+			if end < offset {
+				e.fail(fmt.Errorf("%s: substring expression < 0", strings.TrimSpace(e.text(exp.Arg2))))
+				return ""
+			}
+		} else {
+			end = min(offset+length, end)
+		}
+	}
+	return string(runes[offset:end])
+}
+
+// removeMatch removes the shortest (# and %) or longest (## and %%) prefix
+// or suffix of value matching pattern
+func removeMatch(value, pattern, op string) string {
+	cuts := runeBoundaries(value)
+
+	switch op {
+	case "#", "##":
+		if op == "##" {
+			reverse(cuts)
+		}
+		for _, i := range cuts {
+			if MatchPattern(pattern, value[:i]) {
+				return value[i:]
+			}
+		}
+	case "%", "%%":
+		if op == "%" {
+			reverse(cuts)
+		}
+		for _, i := range cuts {
+			if MatchPattern(pattern, value[i:]) {
+				return value[:i]
+			}
+		}
+	}
+	return value
+}
+
+// replaceMatches replaces the longest match of pattern in value: the first
+// one with /, every one with //, one at the start with /# or at the end
+// with /%. An empty pattern only matches when anchored: ${x/#/pre} prepends.
+func replaceMatches(value, pattern, replacement, op string) string {
+	anchored := op == "/#" || op == "/%"
+	if pattern == "" && !anchored {
+		return value
+	}
+
+	cuts := runeBoundaries(value)
+	var sb strings.Builder
+	done := 0 // value[:done] is already in sb
+
+	for _, start := range cuts {
+		if start < done || op == "/#" && start > 0 {
+			continue
+		}
+
+		end := -1
+		for j := len(cuts) - 1; j >= 0 && cuts[j] >= start; j-- {
+			if op == "/%" && cuts[j] != len(value) {
+				continue
+			}
+			if MatchPattern(pattern, value[start:cuts[j]]) {
+				end = cuts[j]
+				break
+			}
+		}
+		if end < 0 || end == start && !anchored {
+			continue
+		}
+
+		sb.WriteString(value[done:start])
+		sb.WriteString(replacement)
+		done = end
+		if op != "//" {
+			break
+		}
+	}
+
+	sb.WriteString(value[done:])
+	return sb.String()
+}
+
+// convertCase changes the case of the letters matching pattern: every one
+// for ^^ and ,, and only the first letter of the value for ^ and ,
+func convertCase(value, pattern, op string) string {
+	runes := []rune(value)
+	for i, r := range runes {
+		if i > 0 && (op == "^" || op == ",") {
+			break
+		}
+		if !MatchPattern(pattern, string(r)) {
+			continue
+		}
+		if op[0] == '^' {
+			runes[i] = unicode.ToUpper(r)
+		} else {
+			runes[i] = unicode.ToLower(r)
+		}
+	}
+	return string(runes)
+}
+
+// runeBoundaries returns the offsets where value can be cut without
+// splitting a character, from 0 to len(value)
+func runeBoundaries(value string) []int {
+	var cuts []int
+	for i := range value {
+		cuts = append(cuts, i)
+	}
+	return append(cuts, len(value))
+}
+
+func reverse(s []int) {
+	for i, j := 0, len(s)-1; i < j; i, j = i+1, j-1 {
+		s[i], s[j] = s[j], s[i]
+	}
+}
@@ -0,0 +1,151 @@
+package util
+
+import (
+	"fmt"
+	"os"
+	"simple_sh/internal/parser"
+	"strconv"
+)
+
+// FdTable holds the file open on each descriptor of a command: 0, 1 and 2
+// are its standard streams, higher ones come from redirections like
+// 3< file. A nil entry is a closed descriptor.
+type FdTable struct {
+	Files  []*os.File
+	opened []*os.File // files opened by Redirect, closed by Close
+}
+
+// NewFdTable starts a table with files on descriptors 0, 1, 2...
+func NewFdTable(files ...*os.File) *FdTable {
+	return &FdTable{Files: append([]*os.File(nil), files...)}
+}
+
+// Get returns the file open on fd, nil when it is closed
+func (t *FdTable) Get(fd int) *os.File {
+	if fd < len(t.Files) {
+		return t.Files[fd]
+	}
+	return nil
+}
+
+func (t *FdTable) set(fd int, f *os.File) {
+	for len(t.Files) <= fd {
+		t.Files = append(t.Files, nil)
+	}
+	t.Files[fd] = f
+}
+
+// Close closes the files opened by the redirections. Those given to
+// NewFdTable stay open.
+func (t *FdTable) Close() {
+	for _, f := range t.opened {
+		f.Close()
+	}
+	t.opened = nil
+}
+
+// Redirect applies redirections to the table in source order, so that a
+// later one sees the effect of the earlier ones: in cmd > log 2>&1 the
+// descriptor 2 becomes a copy of 1 once 1 is log. On error the files already
+// opened are closed.
+func (t *FdTable) Redirect(redirs []*parser.Redirect) error {
+	for _, redir := range redirs {
+		if err := t.redirect(redir); err != nil {
+			t.Close()
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *FdTable) redirect(redir *parser.Redirect) error {
+	if redir.Op == "<<" || redir.Op == "<<-" {
+		body, err := ExpandWord(redir.Body)
+		if err != nil {
+			return err
+		}
+		return t.feed(redir.Fd, body)
+	}
+
+	var target string
+	var err error
+	if redir.Op == "<<<" {
+		target, err = ExpandWord(redir.Target) // not split, like in bash
+	} else {
+		target, err = ExpandTarget(redir.Target)
+	}
+	if err != nil {
+		return err
+	}
+
+	flags := 0
+	switch redir.Op {
+	case "<":
+		flags = os.O_RDONLY
+	case ">", ">|", "&>":
+		flags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	case ">>", "&>>":
+		// File operations use bit flags to combine multiple options in one integer.
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	case "<>":
+		flags = os.O_CREATE | os.O_RDWR
+	case "<<<":
+		return t.feed(redir.Fd, target+"\n")
+
+	case "<&", ">&":
+		if target == "-" {
+			t.set(redir.Fd, nil)
+			return nil
+		}
+		if n, err := strconv.Atoi(target); err == nil {
+			f := t.Get(n)
+			if f == nil {
+				return fmt.Errorf("%d: bad file descriptor", n)
+			}
+			t.set(redir.Fd, f)
+			return nil
+		}
+		// >& file is an old way of writing &> file
+		if redir.Op == ">&" && redir.Fd == 1 {
+			flags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+			break
+		}
+		return fmt.Errorf("%s: ambiguous redirect", target)
+
+	default:
+		return fmt.Errorf("unsupported redirection %s", redir.Op)
+	}
+
+	f, err := os.OpenFile(target, flags, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open %s: %w", target, err)
+	}
+	t.opened = append(t.opened, f)
+
+	t.set(redir.Fd, f)
+	if redir.Op == "&>" || redir.Op == "&>>" || redir.Op == ">&" {
+		t.set(2, f)
+	}
+	return nil
+}
+
+// feed makes fd read text, from a temporary file: unlike a pipe it holds a
+// text of any size without a writer waiting for the command to read it.
+// The file is removed right away, it lasts as long as it is open.
+func (t *FdTable) feed(fd int, text string) error {
+	f, err := os.CreateTemp("", "simple_sh-heredoc")
+	if err != nil {
+		return fmt.Errorf("cannot create here-document: %w", err)
+	}
+	os.Remove(f.Name())
+	t.opened = append(t.opened, f)
+
+	if _, err := f.WriteString(text); err != nil {
+		return fmt.Errorf("cannot write here-document: %w", err)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return fmt.Errorf("cannot write here-document: %w", err)
+	}
+	t.set(fd, f)
+	return nil
+}
@@ -0,0 +1,288 @@
+package util
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var lastExitStatus int // exit status of the last command, expanded by $?
+
+var shellPid = os.Getpid() // $$, which a subshell keeps from its parent
+var lastBackgroundPid int  // $!, 0 until a job is started in the background
+
+// $0 and the positional parameters $1, $2... of the script being run
+var shellName = "simple_sh"
+var positionalArgs []string
+
+// shellVars holds variables set with NAME=value that were never exported.
+// Exported variables live in the process environment instead, so external
+// commands inherit them.
+var shellVars = map[string]string{}
+
+func SetExitStatus(status int) {
+	lastExitStatus = status
+}
+
+func ExitStatus() int {
+	return lastExitStatus
+}
+
+// SetShellPid sets $$, for a child shell standing for a subshell
+func SetShellPid(pid int) {
+	shellPid = pid
+}
+
+// SetLastBackgroundPid sets $! to the pid of the last process of a job
+// started in the background
+func SetLastBackgroundPid(pid int) {
+	lastBackgroundPid = pid
+}
+
+// SetPositionalArgs sets $0 to name and $1, $2... to args
+func SetPositionalArgs(name string, args []string) {
+	shellName = name
+	positionalArgs = args
+}
+
+// SetArgs replaces $1, $2... with args, as set -- does
+func SetArgs(args []string) {
+	positionalArgs = args
+}
+
+// PositionalArgs returns $1, $2...
+func PositionalArgs() []string {
+	return positionalArgs
+}
+
+// GetVariable returns the value of a variable, "" when it is not set
+func GetVariable(name string) string {
+	return lookupVariable(name)
+}
+
+// SetVariable assigns a shell variable. A variable that is already exported
+// stays exported with its new value.
+func SetVariable(name, value string) {
+	if _, exported := os.LookupEnv(name); exported || exportedLocal(name) {
+		os.Setenv(name, value)
+		return
+	}
+	shellVars[name] = value
+}
+
+// ExportVariable moves a shell variable into the environment
+func ExportVariable(name string) {
+	if value, ok := shellVars[name]; ok {
+		os.Setenv(name, value)
+		delete(shellVars, name)
+	}
+}
+
+// UnsetVariable removes a variable, exported or not
+func UnsetVariable(name string) error {
+	delete(shellVars, name)
+	return os.Unsetenv(name)
+}
+
+// VariableNames returns the names of every shell and environment variable
+func VariableNames() []string {
+	var names []string
+	for name := range shellVars {
+		names = append(names, name)
+	}
+	for _, entry := range os.Environ() {
+		if name, _, ok := strings.Cut(entry, "="); ok && name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ShellVariableNames returns the names of the variables that are not
+// exported, sorted
+func ShellVariableNames() []string {
+	names := slices.Collect(maps.Keys(shellVars))
+	slices.Sort(names)
+	return names
+}
+
+// lookupVariable returns the value of a variable, including the special ones
+func lookupVariable(name string) string {
+	value, _ := lookupParameter(name)
+	return value
+}
+
+// lookupParameter returns the value of a variable or special parameter, and
+// whether it is set at all: ${x-default} tells an unset x from an empty one
+func lookupParameter(name string) (string, bool) {
+	switch name {
+	case "?":
+		return strconv.Itoa(lastExitStatus), true
+	case "0":
+		return shellName, true
+	case "#":
+		return strconv.Itoa(len(positionalArgs)), true
+	case "$":
+		return strconv.Itoa(shellPid), true
+	case "!":
+		if lastBackgroundPid == 0 {
+			return "", false
+		}
+		return strconv.Itoa(lastBackgroundPid), true
+	case "@", "*":
+		return strings.Join(positionalArgs, " "), len(positionalArgs) > 0
+	}
+
+	// $1, $2... and ${10}
+	if n, err := strconv.Atoi(name); err == nil {
+		if n >= 1 && n <= len(positionalArgs) {
+			return positionalArgs[n-1], true
+		}
+		return "", false
+	}
+
+	if value, ok := shellVars[name]; ok {
+		return value, true
+	}
+	return os.LookupEnv(name)
+}
+
+// Each function call pushes a scope. It keeps the positional parameters of
+// the caller, and the values hidden by the variables the function made local:
+// they are put back when it returns. Until then a local variable is visible
+// to the functions it calls too (dynamic scoping, like in bash).
+type scope struct {
+	callerArgs []string
+	hidden     map[string]hiddenVariable
+}
+
+type hiddenVariable struct {
+	value    string
+	set      bool
+	exported bool
+}
+
+var scopes []*scope
+
+// PushScope starts a function call with args as its positional parameters
+func PushScope(args []string) {
+	scopes = append(scopes, &scope{callerArgs: positionalArgs, hidden: map[string]hiddenVariable{}})
+	positionalArgs = args
+}
+
+// PopScope ends a function call, restoring the variables it made local and
+// the positional parameters of the caller
+func PopScope() {
+	s := scopes[len(scopes)-1]
+	scopes = scopes[:len(scopes)-1]
+
+	for name, hidden := range s.hidden {
+		UnsetVariable(name)
+		switch {
+		case !hidden.set:
+		case hidden.exported:
+			os.Setenv(name, hidden.value)
+		default:
+			shellVars[name] = hidden.value
+		}
+	}
+	positionalArgs = s.callerArgs
+}
+
+// MakeLocal makes a variable local to the running function. It starts out
+// unset, but stays exported if the variable it hides was: local FOO=2 puts
+// FOO=2 in the environment of the commands the function runs.
+func MakeLocal(name string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("can only be used in a function")
+	}
+
+	s := scopes[len(scopes)-1]
+	if _, done := s.hidden[name]; !done {
+		hidden := hiddenVariable{exported: exportedLocal(name)}
+		if value, ok := shellVars[name]; ok {
+			hidden = hiddenVariable{value: value, set: true}
+		} else if value, ok := os.LookupEnv(name); ok {
+			hidden = hiddenVariable{value: value, set: true, exported: true}
+		}
+		s.hidden[name] = hidden
+	}
+
+	UnsetVariable(name)
+	return nil
+}
+
+// exportedLocal reports whether name is local to a running function and
+// hides an exported variable, so that assigning it exports it too
+func exportedLocal(name string) bool {
+	for i := len(scopes) - 1; i >= 0; i-- {
+		if hidden, ok := scopes[i].hidden[name]; ok {
+			return hidden.exported
+		}
+	}
+	return false
+}
+
+// SetTemporarily exports a variable with value until the returned function
+// is called, which puts back the variable it hid: FOO=1 f
+func SetTemporarily(name, value string) (restore func()) {
+	envValue, exported := os.LookupEnv(name)
+	shellValue, isShellVar := shellVars[name]
+
+	delete(shellVars, name)
+	os.Setenv(name, value)
+
+	return func() {
+		os.Unsetenv(name)
+		delete(shellVars, name)
+		switch {
+		case exported:
+			os.Setenv(name, envValue)
+		case isShellVar:
+			shellVars[name] = shellValue
+		}
+	}
+}
+
+// VariableState is a copy of every variable and parameter of the shell, and
+// of its options, taken before running commands whose assignments must not
+// last, like the body of a command substitution.
+type VariableState struct {
+	shellVars      map[string]string
+	environ        []string
+	shellName      string
+	positionalArgs []string
+	options        map[string]bool
+}
+
+// SaveVariables copies the current variables, see VariableState
+func SaveVariables() *VariableState {
+	state := &VariableState{
+		shellVars:      make(map[string]string, len(shellVars)),
+		environ:        os.Environ(),
+		shellName:      shellName,
+		positionalArgs: positionalArgs,
+		options:        maps.Clone(options),
+	}
+	for name, value := range shellVars {
+		state.shellVars[name] = value
+	}
+	return state
+}
+
+// Restore puts back the variables as they were when state was saved
+func (state *VariableState) Restore() {
+	shellVars = state.shellVars
+	os.Clearenv()
+	for _, entry := range state.environ {
+		if name, value, ok := strings.Cut(entry, "="); ok && name != "" {
+			os.Setenv(name, value)
+		}
+	}
+	shellName = state.shellName
+	positionalArgs = state.positionalArgs
+	options = state.options
+}